@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/joho/godotenv"
 
 	"github.com/AliRizaAynaci/rlaas/internal/app"
 	"github.com/AliRizaAynaci/rlaas/internal/limiter"
@@ -42,12 +43,22 @@ func gracefulShutdown(app *fiber.App, done chan<- bool) {
 
 func main() {
 	/* ------------ infra ------------ */
-	limiter.InitSharding()
-	logging.L.Info("Redis sharding initialized")
+	_ = godotenv.Load() // limiter reads its env before app.New loads config
+
+	if err := limiter.InitSharding(); err != nil {
+		logging.L.Error("redis sharding init", "err", err)
+		os.Exit(1)
+	}
+	logging.L.Info("Redis sharding initialized", "nodes", len(limiter.Nodes()))
 
 	/* ------------ build Fiber app ------------ */
 	app := app.New() // all wiring (DB, routes, etc.) inside
 
+	// after app.New: the postgres backend and the stored shard list need the DB
+	probeCtx, stopProbes := context.WithCancel(context.Background())
+	defer stopProbes()
+	limiter.StartHealthChecks(probeCtx)
+
 	/* ------------ graceful shutdown ------------ */
 	done := make(chan bool, 1)
 	go gracefulShutdown(app, done)
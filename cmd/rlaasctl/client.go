@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+type client struct {
+	base  string
+	token string
+	http  http.Client
+}
+
+// apiError is the server's {"error": {...}} envelope.
+type apiError struct {
+	Status  int
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields"`
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		msg += "\n  " + k + ": " + e.Fields[k]
+	}
+	return msg
+}
+
+// do sends a request and decodes a JSON response into out (if non-nil).
+// A non-nil in is sent as JSON.
+func (c *client) do(method, path string, q url.Values, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	raw, err := c.raw(method, path, q, body, "application/json", "application/json")
+	if err != nil || out == nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// raw sends a request and returns the response body; any non-2xx answer
+// is also returned as an *apiError.
+func (c *client) raw(method, path string, q url.Values, body io.Reader, contentType, accept string) ([]byte, error) {
+	u := strings.TrimRight(c.base, "/") + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", accept)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	c.http.Timeout = 30 * time.Second
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 == 2 {
+		return b, nil
+	}
+
+	var env struct {
+		Error *apiError `json:"error"`
+	}
+	if json.Unmarshal(b, &env) == nil && env.Error != nil {
+		env.Error.Status = res.StatusCode
+		return b, env.Error
+	}
+	return b, &apiError{Status: res.StatusCode, Code: "http_error", Message: strings.TrimSpace(string(b))}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type project struct {
+	ID               uint      `json:"id"`
+	Name             string    `json:"name"`
+	LimiterTimeoutMs int       `json:"limiter_timeout_ms"`
+	CreatedAt        time.Time `json:"created_at"`
+	Rules            []rule    `json:"rules"`
+	APIKeys          []struct {
+		Name string `json:"name"`
+		Key  string `json:"key"`
+	} `json:"api_keys"`
+}
+
+type rule struct {
+	ID            uint   `json:"id"`
+	ProjectID     uint   `json:"project_id"`
+	Endpoint      string `json:"endpoint"`
+	Strategy      string `json:"strategy"`
+	KeyBy         string `json:"key_by"`
+	LimitCount    int    `json:"limit_count"`
+	WindowSeconds int    `json:"window_seconds"`
+	FailOpen      bool   `json:"fail_open"`
+	FailMode      string `json:"fail_mode"`
+	Consistency   string `json:"consistency"`
+	TimeoutMs     int    `json:"timeout_ms"`
+}
+
+type version struct {
+	Version   int                        `json:"version"`
+	Action    string                     `json:"action"`
+	UserID    uint                       `json:"user_id"`
+	Diff      map[string]json.RawMessage `json:"diff"`
+	CreatedAt time.Time                  `json:"created_at"`
+}
+
+type plan struct {
+	Create []rule `json:"create"`
+	Update []struct {
+		ID       uint                       `json:"id"`
+		Endpoint string                     `json:"endpoint"`
+		Changes  map[string]json.RawMessage `json:"changes"`
+	} `json:"update"`
+	Delete    []rule `json:"delete"`
+	Unchanged int    `json:"unchanged"`
+}
+
+/* ---------- projects ---------- */
+
+func projects(cl *client, out *printer, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "list":
+		var ps []project
+		if err := cl.do("GET", "/projects", nil, nil, &ps); err != nil {
+			return err
+		}
+		rows := make([][]string, len(ps))
+		for i, p := range ps {
+			rows[i] = []string{itoa(int(p.ID)), p.Name, itoa(len(p.APIKeys)), itoa(len(p.Rules)), p.CreatedAt.Format(time.DateTime)}
+		}
+		return out.print(ps, []string{"ID", "NAME", "API KEYS", "RULES", "CREATED"}, rows)
+
+	case "create":
+		if len(args) < 2 {
+			return fmt.Errorf("missing project name: %w", errUsage)
+		}
+		var p project
+		if err := cl.do("POST", "/projects", nil, map[string]string{"project_name": args[1]}, &p); err != nil {
+			return err
+		}
+		var key string
+		if len(p.APIKeys) > 0 {
+			key = p.APIKeys[0].Key
+		}
+		return out.print(p, []string{"ID", "NAME", "API KEY"}, [][]string{{itoa(int(p.ID)), p.Name, key}})
+
+	case "delete":
+		pid, err := id(args, 1, "project id")
+		if err != nil {
+			return err
+		}
+		if err := cl.do("DELETE", "/projects/"+pid, nil, nil, nil); err != nil {
+			return err
+		}
+		return out.line(map[string]string{"deleted": pid}, "project %s deleted", pid)
+	}
+	return fmt.Errorf("unknown projects command %q: %w", args[0], errUsage)
+}
+
+/* ---------- rules ---------- */
+
+func rules(cl *client, out *printer, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	pid, err := id(args, 1, "project id")
+	if err != nil {
+		return err
+	}
+	base := "/projects/" + pid + "/rules"
+
+	switch args[0] {
+	case "list":
+		var rs []rule
+		if err := cl.do("GET", base, nil, nil, &rs); err != nil {
+			return err
+		}
+		sort.Slice(rs, func(i, j int) bool { return rs[i].ID < rs[j].ID })
+		return printRules(out, rs, rs)
+
+	case "create":
+		var r rule
+		if err := ruleFlags(&r, args[2:]); err != nil {
+			return err
+		}
+		if err := cl.do("POST", base, nil, r, &r); err != nil {
+			return err
+		}
+		return printRules(out, r, []rule{r})
+
+	case "update":
+		rid, err := id(args, 2, "rule id")
+		if err != nil {
+			return err
+		}
+		// PUT replaces the rule: start from its current state
+		var rs []rule
+		if err := cl.do("GET", base, nil, nil, &rs); err != nil {
+			return err
+		}
+		var cur *rule
+		for i := range rs {
+			if fmt.Sprint(rs[i].ID) == rid {
+				cur = &rs[i]
+			}
+		}
+		if cur == nil {
+			return fmt.Errorf("rule %s not found in project %s", rid, pid)
+		}
+		if err := ruleFlags(cur, args[3:]); err != nil {
+			return err
+		}
+		if err := cl.do("PUT", base+"/"+rid, nil, cur, nil); err != nil {
+			return err
+		}
+		return printRules(out, cur, []rule{*cur})
+
+	case "delete":
+		rid, err := id(args, 2, "rule id")
+		if err != nil {
+			return err
+		}
+		if err := cl.do("DELETE", base+"/"+rid, nil, nil, nil); err != nil {
+			return err
+		}
+		return out.line(map[string]string{"deleted": rid}, "rule %s deleted", rid)
+
+	case "versions":
+		rid, err := id(args, 2, "rule id")
+		if err != nil {
+			return err
+		}
+		var vs []version
+		if err := cl.do("GET", base+"/"+rid+"/versions", nil, nil, &vs); err != nil {
+			return err
+		}
+		rows := make([][]string, len(vs))
+		for i, v := range vs {
+			rows[i] = []string{itoa(v.Version), v.Action, itoa(int(v.UserID)), v.CreatedAt.Format(time.DateTime), changes(v.Diff)}
+		}
+		return out.print(vs, []string{"VERSION", "ACTION", "USER", "AT", "CHANGES"}, rows)
+
+	case "rollback":
+		rid, err := id(args, 2, "rule id")
+		if err != nil {
+			return err
+		}
+		ver, err := id(args, 3, "version")
+		if err != nil {
+			return err
+		}
+		var r rule
+		if err := cl.do("POST", base+"/"+rid+"/versions/"+ver+"/rollback", nil, nil, &r); err != nil {
+			return err
+		}
+		return printRules(out, r, []rule{r})
+	}
+	return fmt.Errorf("unknown rules command %q: %w", args[0], errUsage)
+}
+
+// ruleFlags applies the flags given in args onto r; unset flags leave r alone.
+func ruleFlags(r *rule, args []string) error {
+	fs := flag.NewFlagSet("rules", flag.ContinueOnError)
+	fs.StringVar(&r.Endpoint, "endpoint", r.Endpoint, "endpoint path")
+	fs.StringVar(&r.Strategy, "strategy", r.Strategy, "fixed_window | sliding_window | token_bucket | leaky_bucket")
+	fs.StringVar(&r.KeyBy, "key-by", r.KeyBy, "api_key | ip | user_id | token | custom")
+	fs.IntVar(&r.LimitCount, "limit", r.LimitCount, "requests per window")
+	fs.IntVar(&r.WindowSeconds, "window", r.WindowSeconds, "window in seconds")
+	fs.BoolVar(&r.FailOpen, "fail-open", r.FailOpen, "allow when the backend is down")
+	fs.StringVar(&r.FailMode, "fail-mode", r.FailMode, "open | closed | local")
+	fs.StringVar(&r.Consistency, "consistency", r.Consistency, "strict | approximate")
+	fs.IntVar(&r.TimeoutMs, "timeout-ms", r.TimeoutMs, "limiter deadline in ms")
+	return fs.Parse(args)
+}
+
+func printRules(out *printer, v any, rs []rule) error {
+	rows := make([][]string, len(rs))
+	for i, r := range rs {
+		mode := r.FailMode
+		if mode == "" {
+			mode = map[bool]string{true: "open", false: "closed"}[r.FailOpen]
+		}
+		rows[i] = []string{itoa(int(r.ID)), r.Endpoint, r.Strategy, r.KeyBy, itoa(r.LimitCount), itoa(r.WindowSeconds) + "s", mode, r.Consistency}
+	}
+	return out.print(v, []string{"ID", "ENDPOINT", "STRATEGY", "KEY BY", "LIMIT", "WINDOW", "FAIL MODE", "CONSISTENCY"}, rows)
+}
+
+// changes renders a diff as "field: from -> to, ...".
+func changes(d map[string]json.RawMessage) string {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		var c struct{ From, To json.RawMessage }
+		_ = json.Unmarshal(d[k], &c)
+		parts[i] = k + ": " + string(orNull(c.From)) + " -> " + string(orNull(c.To))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func orNull(b json.RawMessage) json.RawMessage {
+	if len(b) == 0 {
+		return json.RawMessage("null")
+	}
+	return b
+}
+
+/* ---------- config as code ---------- */
+
+func export(cl *client, args []string) error {
+	pid, err := id(args, 0, "project id")
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "yaml", "yaml or json")
+	env := fs.String("env", "prod", "environment")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	b, err := cl.raw("GET", "/projects/"+pid+"/config", url.Values{"format": {*format}, "env": {*env}}, nil, "", "application/"+*format)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+func applyConfig(cl *client, out *printer, args []string, dryRun bool) error {
+	pid, err := id(args, 0, "project id")
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	file := fs.String("f", "", "config file (.yaml, .yml or .json)")
+	env := fs.String("env", "prod", "environment")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("-f is required: %w", errUsage)
+	}
+	body, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	ct := "application/json"
+	if ext := filepath.Ext(*file); ext == ".yaml" || ext == ".yml" {
+		ct = "application/yaml"
+	}
+
+	b, err := cl.raw("PUT", "/projects/"+pid+"/config", url.Values{"dry_run": {fmt.Sprint(dryRun)}, "env": {*env}},
+		bytes.NewReader(body), ct, "application/json")
+	if err != nil {
+		return err
+	}
+	var res struct {
+		DryRun bool `json:"dry_run"`
+		Plan   plan `json:"plan"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+
+	p := res.Plan
+	var rows [][]string
+	for _, r := range p.Create {
+		rows = append(rows, []string{"+", "", r.Endpoint, fmt.Sprintf("%s %d/%ds", r.Strategy, r.LimitCount, r.WindowSeconds)})
+	}
+	for _, u := range p.Update {
+		rows = append(rows, []string{"~", itoa(int(u.ID)), u.Endpoint, changes(u.Changes)})
+	}
+	for _, r := range p.Delete {
+		rows = append(rows, []string{"-", itoa(int(r.ID)), r.Endpoint, fmt.Sprintf("%s %d/%ds", r.Strategy, r.LimitCount, r.WindowSeconds)})
+	}
+	if err := out.print(res, []string{"", "ID", "ENDPOINT", "DETAIL"}, rows); err != nil {
+		return err
+	}
+	if out.json {
+		return nil
+	}
+	verb := "applied"
+	if dryRun {
+		verb = "would apply (dry run)"
+	}
+	_, err = fmt.Fprintf(out.w, "%s: %d to create, %d to update, %d to delete, %d unchanged\n",
+		verb, len(p.Create), len(p.Update), len(p.Delete), p.Unchanged)
+	return err
+}
+
+/* ---------- runtime ---------- */
+
+func check(cl *client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	apiKey := fs.String("api-key", "", "project API key")
+	endpoint := fs.String("endpoint", "", "endpoint")
+	key := fs.String("key", "", "client key (IP, user id, ...)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	req, _ := json.Marshal(map[string]string{"api_key": *apiKey, "endpoint": *endpoint, "key": *key})
+	var res struct {
+		Allowed bool   `json:"allowed"`
+		Reason  string `json:"reason"`
+		Timeout bool   `json:"timeout,omitempty"`
+	}
+	b, err := cl.raw("POST", "/check", nil, bytes.NewReader(req), "application/json", "application/json")
+	if e, ok := err.(*apiError); ok && e.Status == 429 {
+		err = nil // a denial is an answer, not a failure
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+	return out.print(res, []string{"ALLOWED", "REASON"}, [][]string{{fmt.Sprint(res.Allowed), res.Reason}})
+}
+
+func state(cl *client, out *printer, args []string) error {
+	pid, err := id(args, 0, "project id")
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("state", flag.ContinueOnError)
+	endpoint := fs.String("endpoint", "", "endpoint")
+	key := fs.String("key", "", "client key")
+	env := fs.String("env", "prod", "environment")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var res struct {
+		Rules []struct {
+			RuleID        uint   `json:"rule_id"`
+			Strategy      string `json:"strategy"`
+			Limit         int    `json:"limit"`
+			WindowSeconds int    `json:"window_seconds"`
+			Remaining     int    `json:"remaining"`
+		} `json:"rules"`
+	}
+	q := url.Values{"endpoint": {*endpoint}, "key": {*key}, "env": {*env}}
+	if err := cl.do("GET", "/projects/"+pid+"/keys/state", q, nil, &res); err != nil {
+		return err
+	}
+	rows := make([][]string, len(res.Rules))
+	for i, r := range res.Rules {
+		rows[i] = []string{itoa(int(r.RuleID)), r.Strategy, itoa(r.Limit), itoa(r.WindowSeconds) + "s", itoa(r.Remaining)}
+	}
+	return out.print(res, []string{"RULE", "STRATEGY", "LIMIT", "WINDOW", "REMAINING"}, rows)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// request is what the fake server saw.
+type request struct {
+	Method, Path, Query, ContentType, Auth string
+	Body                                   string
+}
+
+// fakeServer answers each "METHOD /path" from replies (status 200 unless
+// the reply starts with a three-digit status and a space) and records
+// every request.
+func fakeServer(t *testing.T, replies map[string]string) (*client, *[]request) {
+	t.Helper()
+	var seen []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		seen = append(seen, request{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(b)})
+		reply, ok := replies[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		status := http.StatusOK
+		if len(reply) > 4 && reply[3] == ' ' {
+			status = map[string]int{"404": 404, "422": 422, "429": 429}[reply[:3]]
+			reply = reply[4:]
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return &client{base: srv.URL + "/", token: "rlaas_pat_test"}, &seen
+}
+
+func table() (*printer, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return &printer{w: &buf}, &buf
+}
+
+func TestProjectsCreate(t *testing.T) {
+	cl, seen := fakeServer(t, map[string]string{
+		"POST /projects": `{"id":3,"name":"shop","api_keys":[{"name":"default","key":"rlaas_key_abc"}]}`,
+	})
+	out, buf := table()
+	if err := projects(cl, out, []string{"create", "shop"}); err != nil {
+		t.Fatal(err)
+	}
+	r := (*seen)[0]
+	if r.Auth != "Bearer rlaas_pat_test" || r.ContentType != "application/json" || r.Body != `{"project_name":"shop"}` {
+		t.Fatalf("request = %+v", r)
+	}
+	if !strings.Contains(buf.String(), "rlaas_key_abc") {
+		t.Fatalf("output lacks the new key:\n%s", buf)
+	}
+}
+
+// update sends the whole rule: the current one with the flags applied.
+func TestRulesUpdate(t *testing.T) {
+	cl, seen := fakeServer(t, map[string]string{
+		"GET /projects/3/rules": `[{"id":7,"endpoint":"/pay","strategy":"token_bucket","key_by":"ip","limit_count":10,"window_seconds":60},
+			{"id":8,"endpoint":"/other","strategy":"fixed_window","key_by":"ip","limit_count":1,"window_seconds":1}]`,
+		"PUT /projects/3/rules/7": `{}`,
+	})
+	out, _ := table()
+	if err := rules(cl, out, []string{"update", "3", "7", "-limit", "50", "-fail-mode", "local"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*seen) != 2 || (*seen)[1].Method != "PUT" {
+		t.Fatalf("requests = %+v", *seen)
+	}
+	var sent rule
+	if err := json.Unmarshal([]byte((*seen)[1].Body), &sent); err != nil {
+		t.Fatal(err)
+	}
+	want := rule{ID: 7, Endpoint: "/pay", Strategy: "token_bucket", KeyBy: "ip", LimitCount: 50, WindowSeconds: 60, FailMode: "local"}
+	if sent != want {
+		t.Fatalf("PUT body = %+v, want %+v", sent, want)
+	}
+}
+
+func TestRulesRollback(t *testing.T) {
+	cl, seen := fakeServer(t, map[string]string{
+		"POST /projects/3/rules/7/versions/2/rollback": `{"id":7,"endpoint":"/pay","limit_count":10}`,
+	})
+	out, _ := table()
+	if err := rules(cl, out, []string{"rollback", "3", "7", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := (*seen)[0]; r.Body != "" || r.ContentType != "" {
+		t.Fatalf("rollback sent a body: %+v", r)
+	}
+	if err := rules(cl, out, []string{"rollback", "3", "7", "latest"}); err == nil || len(*seen) != 1 {
+		t.Fatal("a non-numeric version was sent")
+	}
+}
+
+func TestDiffSendsFileAsDryRun(t *testing.T) {
+	cl, seen := fakeServer(t, map[string]string{
+		"PUT /projects/3/config": `{"dry_run":true,"plan":{"create":[{"endpoint":"/new","strategy":"fixed_window","limit_count":5,"window_seconds":1}],"unchanged":2}}`,
+	})
+	file := filepath.Join(t.TempDir(), "rules.yml")
+	if err := os.WriteFile(file, []byte("rules: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, buf := table()
+	if err := applyConfig(cl, out, []string{"3", "-f", file, "-env", "staging"}, true); err != nil {
+		t.Fatal(err)
+	}
+	r := (*seen)[0]
+	if r.Query != "dry_run=true&env=staging" || r.ContentType != "application/yaml" || r.Body != "rules: []\n" {
+		t.Fatalf("request = %+v", r)
+	}
+	if !strings.Contains(buf.String(), "would apply (dry run): 1 to create, 0 to update, 0 to delete, 2 unchanged") {
+		t.Fatalf("output:\n%s", buf)
+	}
+}
+
+// A 429 is a denial to print, not a failure.
+func TestCheckDenied(t *testing.T) {
+	cl, seen := fakeServer(t, map[string]string{
+		"POST /check": `429 {"allowed":false,"reason":"rule 7"}`,
+	})
+	out, buf := table()
+	if err := check(cl, out, []string{"-api-key", "rlaas_key_abc", "-endpoint", "/pay", "-key", "1.2.3.4"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := (*seen)[0]; r.Body != `{"api_key":"rlaas_key_abc","endpoint":"/pay","key":"1.2.3.4"}` {
+		t.Fatalf("body = %s", r.Body)
+	}
+	if !strings.Contains(buf.String(), "false") || !strings.Contains(buf.String(), "rule 7") {
+		t.Fatalf("output:\n%s", buf)
+	}
+}
+
+func TestStateJSON(t *testing.T) {
+	reply := `{"rules":[{"rule_id":7,"strategy":"token_bucket","limit":10,"window_seconds":60,"remaining":4}]}`
+	cl, seen := fakeServer(t, map[string]string{"GET /projects/3/keys/state": reply})
+	var buf bytes.Buffer
+	if err := state(cl, &printer{json: true, w: &buf}, []string{"3", "-endpoint", "/pay", "-key", "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if q := (*seen)[0].Query; q != "endpoint=%2Fpay&env=prod&key=u1" {
+		t.Fatalf("query = %s", q)
+	}
+	var got, want any
+	_ = json.Unmarshal(buf.Bytes(), &got)
+	_ = json.Unmarshal([]byte(reply), &want)
+	if !jsonEqual(got, want) {
+		t.Fatalf("json output = %s", buf.String())
+	}
+}
+
+func jsonEqual(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+func TestAPIError(t *testing.T) {
+	cl, _ := fakeServer(t, map[string]string{
+		"POST /projects/3/rules": `422 {"error":{"code":"validation_failed","message":"invalid rule","fields":{"window_seconds":"must be positive","endpoint":"must start with /"}}}`,
+	})
+	out, _ := table()
+	err := rules(cl, out, []string{"create", "3", "-endpoint", "x"})
+	var e *apiError
+	if !errors.As(err, &e) || e.Status != 422 {
+		t.Fatalf("err = %v", err)
+	}
+	want := "422 validation_failed: invalid rule\n  endpoint: must start with /\n  window_seconds: must be positive"
+	if err.Error() != want {
+		t.Fatalf("error = %q, want %q", err.Error(), want)
+	}
+
+	if err := projects(cl, out, []string{"delete", "9"}); !errors.As(err, &e) || e.Status != 404 || e.Code != "http_error" {
+		t.Fatalf("plain 404: %v", err)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	cl, seen := fakeServer(t, nil)
+	out, _ := table()
+	for _, args := range [][]string{{}, {"create"}, {"frobnicate", "x"}} {
+		if err := projects(cl, out, args); !errors.Is(err, errUsage) {
+			t.Errorf("projects %v: %v, want a usage error", args, err)
+		}
+	}
+	if err := applyConfig(cl, out, []string{"3"}, false); !errors.Is(err, errUsage) {
+		t.Errorf("apply without -f: %v", err)
+	}
+	if len(*seen) != 0 {
+		t.Fatalf("usage errors sent requests: %+v", *seen)
+	}
+}
@@ -0,0 +1,113 @@
+// Command rlaasctl manages rlaas projects and rules from the command line.
+//
+//	rlaasctl [-server URL] [-token TOKEN] [-o table|json] <command> [args]
+//
+// The token is sent as "Authorization: Bearer"; -server and -token default
+// to $RLAAS_SERVER and $RLAAS_TOKEN.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const usage = `usage: rlaasctl [-server URL] [-token TOKEN] [-o table|json] <command> [args]
+
+projects:
+  projects list
+  projects create <name>
+  projects delete <pid>
+
+rules:
+  rules list <pid>
+  rules create <pid> -endpoint /x -strategy token_bucket -limit 100 -window 60 [flags]
+  rules update <pid> <rid> [-limit N] [-window S] [flags]
+  rules delete <pid> <rid>
+  rules versions <pid> <rid>
+  rules rollback <pid> <rid> <version>
+
+config as code:
+  export <pid> [-format yaml|json] [-env prod]
+  diff   <pid> -f rules.yaml [-env prod]   show what apply would change
+  apply  <pid> -f rules.yaml [-env prod]   make the environment match the file
+
+runtime:
+  check -api-key KEY -endpoint /x -key USER     run a real /check (consumes quota)
+  state <pid> -endpoint /x -key USER [-env prod]  remaining quota per rule
+`
+
+func main() {
+	fs := flag.NewFlagSet("rlaasctl", flag.ExitOnError)
+	server := fs.String("server", envOr("RLAAS_SERVER", "http://localhost:8080"), "rlaas base URL")
+	token := fs.String("token", os.Getenv("RLAAS_TOKEN"), "API token")
+	output := fs.String("o", "table", "output format: table or json")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	_ = fs.Parse(os.Args[1:])
+
+	if *output != "table" && *output != "json" {
+		fail(errors.New("-o must be table or json"))
+	}
+	cl := &client{base: *server, token: *token}
+	out := &printer{json: *output == "json", w: os.Stdout}
+
+	args := fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "projects":
+		err = projects(cl, out, args[1:])
+	case "rules":
+		err = rules(cl, out, args[1:])
+	case "export":
+		err = export(cl, args[1:])
+	case "diff":
+		err = applyConfig(cl, out, args[1:], true)
+	case "apply":
+		err = applyConfig(cl, out, args[1:], false)
+	case "check":
+		err = check(cl, out, args[1:])
+	case "state":
+		err = state(cl, out, args[1:])
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if errors.Is(err, errUsage) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+var errUsage = errors.New("usage")
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "rlaasctl:", err)
+	os.Exit(1)
+}
+
+func envOr(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+// id parses a positional numeric argument.
+func id(args []string, i int, name string) (string, error) {
+	if len(args) <= i {
+		return "", fmt.Errorf("missing %s: %w", name, errUsage)
+	}
+	if _, err := strconv.ParseUint(args[i], 10, 0); err != nil {
+		return "", fmt.Errorf("%s must be a number, got %q", name, args[i])
+	}
+	return args[i], nil
+}
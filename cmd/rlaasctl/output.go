@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer renders results either as JSON (the value as received) or as a
+// table of the given rows.
+type printer struct {
+	json bool
+	w    io.Writer
+}
+
+func (p *printer) print(v any, header []string, rows [][]string) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	return tw.Flush()
+}
+
+// line prints a one-line message in table mode, or v in JSON mode.
+func (p *printer) line(v any, format string, args ...any) error {
+	if p.json {
+		return p.print(v, nil, nil)
+	}
+	_, err := fmt.Fprintf(p.w, format+"\n", args...)
+	return err
+}
+
+func itoa(n int) string { return fmt.Sprint(n) }
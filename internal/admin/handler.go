@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
+	"github.com/AliRizaAynaci/rlaas/internal/limiter"
+	"github.com/AliRizaAynaci/rlaas/internal/logging"
+)
+
+// Handler exposes operator-only controls. Topology changes are stored and
+// published, so every replica routes by the same shard list.
+type Handler struct{}
+
+func NewHandler() *Handler { return &Handler{} }
+
+// GET /admin/shards
+func (h *Handler) ListShards(c *fiber.Ctx) error {
+	return c.JSON(limiter.Nodes())
+}
+
+// POST /admin/shards  { "url": "redis://...", "weight": 1 }
+func (h *Handler) AddShard(c *fiber.Ctx) error {
+	var n limiter.Node
+	if err := c.BodyParser(&n); err != nil || n.URL == "" {
+		return fiber.ErrBadRequest
+	}
+
+	switch err := limiter.AddNode(n); {
+	case errors.Is(err, limiter.ErrBadNodeURL):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, limiter.ErrNodeExists), errors.Is(err, limiter.ErrNotSharded):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case err != nil:
+		return topologyError(err)
+	}
+
+	logging.L.Info("shard added", "url", n.URL, "weight", n.Weight)
+	return c.Status(fiber.StatusCreated).JSON(limiter.Nodes())
+}
+
+// DELETE /admin/shards?url=redis://...
+func (h *Handler) DrainShard(c *fiber.Ctx) error {
+	url := c.Query("url")
+	if url == "" {
+		return fiber.ErrBadRequest
+	}
+
+	switch err := limiter.DrainNode(url); {
+	case errors.Is(err, limiter.ErrNodeNotFound):
+		return fiber.ErrNotFound
+	case errors.Is(err, limiter.ErrLastNode), errors.Is(err, limiter.ErrNotSharded):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case err != nil:
+		return topologyError(err)
+	}
+
+	logging.L.Info("shard drained", "url", url)
+	return c.JSON(limiter.Nodes())
+}
+
+// topologyError maps failures that aren't the caller's fault: 503 until
+// sharding is up, otherwise a 500 that keeps err out of the response.
+func topologyError(err error) error {
+	if errors.Is(err, limiter.ErrNoTopology) {
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+	return httperr.Internal(err)
+}
+
+// GET /admin/metrics  (expvar, e.g. config_cache hit rates)
+func (h *Handler) Metrics(c *fiber.Ctx) error {
+	out := make(map[string]json.RawMessage)
+	expvar.Do(func(kv expvar.KeyValue) {
+		out[kv.Key] = json.RawMessage(kv.Value.String())
+	})
+	return c.JSON(out)
+}
@@ -0,0 +1,35 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Prefix marks a secret as a project API key.
+const Prefix = "rlaas_key_"
+
+// New makes a key with a fresh secret; the secret is only in k.Secret.
+func New(name string) (Key, error) {
+	secret, err := Generate()
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Name: name, Prefix: secret[:len(Prefix)+6], Hash: Hash(secret), Secret: secret}, nil
+}
+
+func Generate() (string, error) {
+	bytes := make([]byte, 32) // 256-bit key
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return Prefix + hex.EncodeToString(bytes), nil
+}
+
+// Hash is what is stored and looked up. A plain SHA-256 is enough for
+// 256 random bits and keeps /check lookups cheap.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,122 @@
+package apikey
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
+)
+
+type Handler struct{ svc *Service }
+
+func NewHandler(s *Service) *Handler { return &Handler{s} }
+
+/* helpers */
+func pid(c *fiber.Ctx) uint { id, _ := strconv.Atoi(c.Params("pid")); return uint(id) }
+func kid(c *fiber.Ctx) uint { id, _ := strconv.Atoi(c.Params("kid")); return uint(id) }
+
+// apiError maps service errors onto the API error envelope.
+func apiError(err error) error {
+	switch {
+	case errors.Is(err, ErrProjectNotFound):
+		return httperr.NotFound("project not found")
+	case errors.Is(err, ErrNotFound):
+		return httperr.NotFound(err.Error())
+	case errors.Is(err, ErrForbidden):
+		return httperr.Forbidden(err.Error())
+	case errors.Is(err, environment.ErrNotFound):
+		return httperr.NotFound(err.Error())
+	case errors.Is(err, ErrUnknownRule):
+		return httperr.Invalid(map[string]string{"rule_ids": err.Error()})
+	case errors.Is(err, ErrRotating):
+		return httperr.Conflict(err.Error())
+	}
+	return httperr.Internal(err)
+}
+
+// GET /projects/:pid/api-keys
+func (h *Handler) List(c *fiber.Ctx) error {
+	ks, err := h.svc.List(c.Locals("user_id").(uint), pid(c))
+	if err != nil {
+		return apiError(err)
+	}
+	return c.JSON(ks)
+}
+
+// POST /projects/:pid/api-keys  { "name": "billing", "environment": "prod", "endpoints": ["/pay"], "rule_ids": [3] }
+// The secret ("key") is in the response only.
+func (h *Handler) Create(c *fiber.Ctx) error {
+	var req struct {
+		Name        string   `json:"name"`
+		Environment string   `json:"environment"` // default prod
+		Endpoints   []string `json:"endpoints"`
+		RuleIDs     []uint   `json:"rule_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return httperr.BadRequest("body is not valid JSON")
+	}
+
+	bad := make(map[string]string)
+	if req.Name == "" || len(req.Name) > 100 {
+		bad["name"] = "must be 1-100 characters"
+	}
+	for _, e := range req.Endpoints {
+		if !strings.HasPrefix(e, "/") {
+			bad["endpoints"] = "every endpoint must start with /"
+		}
+	}
+	if len(bad) > 0 {
+		return httperr.Invalid(bad)
+	}
+
+	if req.Environment == "" {
+		req.Environment = environment.Default
+	}
+	k, err := h.svc.Create(c.Locals("user_id").(uint), pid(c), req.Environment, req.Name, req.Endpoints, req.RuleIDs)
+	if err != nil {
+		return apiError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(k)
+}
+
+// DELETE /projects/:pid/api-keys/:kid
+func (h *Handler) Revoke(c *fiber.Ctx) error {
+	if err := h.svc.Revoke(c.Locals("user_id").(uint), pid(c), kid(c)); err != nil {
+		return apiError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// POST /projects/:pid/api-keys/:kid/rotate  { "grace_seconds": 3600 }
+// The new secret is in the response only.
+// The old secret stays valid for grace_seconds (default API_KEY_GRACE_SECONDS).
+func (h *Handler) Rotate(c *fiber.Ctx) error {
+	var req struct {
+		GraceSeconds *int `json:"grace_seconds"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return httperr.BadRequest("body is not valid JSON")
+		}
+	}
+	grace := DefaultGrace()
+	if req.GraceSeconds != nil {
+		grace = time.Duration(*req.GraceSeconds) * time.Second
+		if grace < 0 || grace > MaxGrace {
+			return httperr.Invalid(map[string]string{
+				"grace_seconds": "must be between 0 and " + strconv.Itoa(int(MaxGrace.Seconds())),
+			})
+		}
+	}
+
+	secret, until, err := h.svc.Rotate(c.Locals("user_id").(uint), pid(c), kid(c), grace)
+	if err != nil {
+		return apiError(err)
+	}
+	return c.JSON(fiber.Map{"key": secret, "previous_key_expires_at": until})
+}
@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
+)
+
+func testApp(s *Service) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", adminID)
+		return c.Next()
+	})
+	h := NewHandler(s)
+	app.Get("/projects/:pid/api-keys", h.List)
+	app.Post("/projects/:pid/api-keys", h.Create)
+	app.Post("/projects/:pid/api-keys/:kid/rotate", h.Rotate)
+	return app
+}
+
+func call(t *testing.T, app *fiber.App, method, path, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(b)
+}
+
+// The secret is in the create and rotate responses and nowhere else; the
+// hashes are never serialized.
+func TestSecretShownOnce(t *testing.T) {
+	s, r, _ := newTestService()
+	app := testApp(s)
+
+	status, body := call(t, app, "POST", "/projects/1/api-keys", `{"name":"ci"}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("create: %d %s", status, body)
+	}
+	var created struct {
+		ID  uint   `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Key, Prefix) || Hash(created.Key) != r.keys[0].Hash {
+		t.Fatalf("create response has no usable secret: %s", body)
+	}
+
+	listed := func() {
+		t.Helper()
+		status, body := call(t, app, "GET", "/projects/1/api-keys", "")
+		if status != fiber.StatusOK {
+			t.Fatalf("list: %d %s", status, body)
+		}
+		var ks []map[string]any
+		if err := json.Unmarshal([]byte(body), &ks); err != nil || len(ks) != 1 {
+			t.Fatalf("list: %s", body)
+		}
+		for _, field := range []string{"key", "hash", "previous_hash"} {
+			if _, ok := ks[0][field]; ok {
+				t.Errorf("list includes %q: %s", field, body)
+			}
+		}
+		if ks[0]["prefix"] != r.keys[0].Prefix {
+			t.Errorf("list prefix = %v, want %q", ks[0]["prefix"], r.keys[0].Prefix)
+		}
+	}
+	listed()
+
+	status, body = call(t, app, "POST", "/projects/1/api-keys/1/rotate", `{"grace_seconds":60}`)
+	if status != fiber.StatusOK {
+		t.Fatalf("rotate: %d %s", status, body)
+	}
+	var rotated struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal([]byte(body), &rotated); err != nil || Hash(rotated.Key) != r.keys[0].Hash {
+		t.Fatalf("rotate response has no usable secret: %s", body)
+	}
+	listed()
+
+	if status, body := call(t, app, "POST", "/projects/1/api-keys/1/rotate", `{}`); status != fiber.StatusConflict {
+		t.Fatalf("rotate during grace: %d %s, want 409", status, body)
+	}
+}
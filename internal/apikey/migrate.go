@@ -0,0 +1,72 @@
+package apikey
+
+import "gorm.io/gorm"
+
+// MigrateLegacy brings keys stored by older versions into api_keys as
+// hashes. Both steps are no-ops once done:
+//
+//   - keys from the old projects.api_key column become a key named
+//     "default", and the column is dropped;
+//   - plaintext api_keys.key / previous_key values are hashed, and the
+//     columns are dropped.
+//
+// Counter namespaces that were a plaintext key are hashed too, which
+// resets those projects' counters once.
+func MigrateLegacy(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&Key{}) {
+		return nil
+	}
+	if m.HasColumn("projects", "api_key") {
+		if err := migrateProjectKeys(db); err != nil {
+			return err
+		}
+	}
+	if m.HasColumn(&Key{}, "key") {
+		return hashPlaintextKeys(db)
+	}
+	return nil
+}
+
+// hashOf is Hash in SQL.
+func hashOf(expr string) string { return `encode(sha256(convert_to(` + expr + `, 'UTF8')), 'hex')` }
+
+func migrateProjectKeys(db *gorm.DB) error {
+	prev := `'', NULL::timestamptz`
+	if db.Migrator().HasColumn("projects", "previous_api_key") {
+		prev = `CASE WHEN COALESCE(previous_api_key, '') = '' THEN '' ELSE ` + hashOf("previous_api_key") + ` END,
+				previous_key_expires_at`
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO api_keys (project_id, name, prefix, hash, previous_hash, previous_key_expires_at, created_at)
+			SELECT id, 'default', left(api_key, 8), ` + hashOf("api_key") + `, ` + prev + `, created_at
+			FROM projects
+			WHERE COALESCE(api_key, '') <> ''`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`UPDATE projects SET counter_key = ` + hashOf("COALESCE(NULLIF(counter_key, ''), api_key)")).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`ALTER TABLE projects
+			DROP COLUMN api_key,
+			DROP COLUMN IF EXISTS previous_api_key,
+			DROP COLUMN IF EXISTS previous_key_expires_at`).Error
+	})
+}
+
+func hashPlaintextKeys(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE projects SET counter_key = ` + hashOf("counter_key") + `
+			WHERE counter_key IN (SELECT key FROM api_keys UNION SELECT previous_key FROM api_keys)`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`UPDATE api_keys SET
+				prefix = left(key, 8),
+				hash = ` + hashOf("key") + `,
+				previous_hash = CASE WHEN COALESCE(previous_key, '') = '' THEN '' ELSE ` + hashOf("previous_key") + ` END
+			WHERE COALESCE(hash, '') = ''`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`ALTER TABLE api_keys DROP COLUMN key, DROP COLUMN IF EXISTS previous_key`).Error
+	})
+}
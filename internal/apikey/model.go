@@ -0,0 +1,38 @@
+package apikey
+
+import (
+	"slices"
+	"time"
+)
+
+// Key is a credential for /check. A project can have any number of them;
+// Endpoints and RuleIDs, when set, narrow what the key may be used for.
+// Only a SHA-256 of the secret is stored; Prefix tells keys apart.
+type Key struct {
+	ID                   uint       `json:"id"           gorm:"primaryKey"`
+	ProjectID            uint       `json:"project_id"   gorm:"index;not null"`
+	Environment          string     `json:"environment"  gorm:"not null;default:prod"` // whose rules /check applies
+	Name                 string     `json:"name"`
+	Prefix               string     `json:"prefix"`
+	Hash                 string     `json:"-"            gorm:"uniqueIndex"`
+	PreviousHash         string     `json:"-"            gorm:"index"` // still accepted until PreviousKeyExpiresAt
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at"`
+	Endpoints            []string   `json:"endpoints"    gorm:"serializer:json;type:jsonb"` // empty = all
+	RuleIDs              []uint     `json:"rule_ids"     gorm:"serializer:json;type:jsonb"` // empty = all
+	LastUsedAt           *time.Time `json:"last_used_at"`
+	CreatedAt            time.Time  `json:"created_at"`
+
+	Secret string `json:"key,omitempty" gorm:"-"` // set only right after create or rotate
+}
+
+func (Key) TableName() string { return "api_keys" }
+
+// AllowsEndpoint reports whether the key may check endpoint.
+func (k *Key) AllowsEndpoint(endpoint string) bool {
+	return len(k.Endpoints) == 0 || slices.Contains(k.Endpoints, endpoint)
+}
+
+// AllowsRule reports whether the key's checks evaluate rule id.
+func (k *Key) AllowsRule(id uint) bool {
+	return len(k.RuleIDs) == 0 || slices.Contains(k.RuleIDs, id)
+}
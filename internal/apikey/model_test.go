@@ -0,0 +1,22 @@
+package apikey
+
+import "testing"
+
+func TestRestrictions(t *testing.T) {
+	open := Key{}
+	if !open.AllowsEndpoint("/anything") || !open.AllowsRule(42) {
+		t.Fatal("a key without restrictions refused something")
+	}
+
+	k := Key{Endpoints: []string{"/pay", "/refund"}, RuleIDs: []uint{3}}
+	for ep, want := range map[string]bool{"/pay": true, "/refund": true, "/pay/": false, "/other": false, "": false} {
+		if got := k.AllowsEndpoint(ep); got != want {
+			t.Errorf("AllowsEndpoint(%q) = %v, want %v", ep, got, want)
+		}
+	}
+	for id, want := range map[uint]bool{3: true, 4: false, 0: false} {
+		if got := k.AllowsRule(id); got != want {
+			t.Errorf("AllowsRule(%d) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package apikey
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/changefeed"
+)
+
+type gormRepo struct{ db *gorm.DB }
+
+func NewGormRepo(db *gorm.DB) Repository { return &gormRepo{db} }
+
+func (r *gormRepo) Create(k *Key) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(k).Error; err != nil {
+			return err
+		}
+		return changefeed.Publish(tx, k.ProjectID)
+	})
+}
+
+func (r *gormRepo) ListByProject(pid uint) ([]Key, error) {
+	var ks []Key
+	return ks, r.db.Where("project_id = ?", pid).Order("id").Find(&ks).Error
+}
+
+// Delete reports false when no key matched (id, pid).
+func (r *gormRepo) Delete(id, pid uint) (bool, error) {
+	var ok bool
+	return ok, r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND project_id = ?", id, pid).Delete(&Key{})
+		if ok = res.RowsAffected > 0; res.Error != nil || !ok {
+			return res.Error
+		}
+		return changefeed.Publish(tx, pid)
+	})
+}
+
+// Rotate reports false when no key matched (id, pid), and ErrRotating while
+// the secret replaced last time is still in its grace period.
+func (r *gormRepo) Rotate(id, pid uint, next Key, graceUntil time.Time) (bool, error) {
+	var ok bool
+	return ok, r.db.Transaction(func(tx *gorm.DB) error {
+		var inGrace []bool
+		if err := tx.Raw(`SELECT COALESCE(previous_key_expires_at > NOW(), false)
+			FROM api_keys WHERE id = ? AND project_id = ? FOR UPDATE`, id, pid).Scan(&inGrace).Error; err != nil {
+			return err
+		}
+		if len(inGrace) == 0 {
+			return nil
+		}
+		if inGrace[0] {
+			return ErrRotating
+		}
+		res := tx.Exec(`UPDATE api_keys SET
+				previous_hash = hash,
+				previous_key_expires_at = ?,
+				hash = ?,
+				prefix = ?
+			WHERE id = ? AND project_id = ?`, graceUntil, next.Hash, next.Prefix, id, pid)
+		if ok = res.RowsAffected > 0; res.Error != nil || !ok {
+			return res.Error
+		}
+		return changefeed.Publish(tx, pid)
+	})
+}
@@ -0,0 +1,119 @@
+package apikey
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/AliRizaAynaci/rlaas/internal/changefeed"
+)
+
+// projectRow is enough of a project for the api_keys foreign key.
+type projectRow struct {
+	ID   uint
+	Name string
+}
+
+func (projectRow) TableName() string { return "projects" }
+
+// testRepo needs a scratch database in LIMITER_TEST_POSTGRES_DSN, e.g. the
+// docker-compose Postgres. Keys go to a new project, removed afterwards.
+func testRepo(t *testing.T) (Repository, *gorm.DB, uint) {
+	dsn := os.Getenv("LIMITER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("LIMITER_TEST_POSTGRES_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&projectRow{}, &Key{}, &changefeed.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	p := projectRow{Name: "apikey-test"}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("project_id = ?", p.ID).Delete(&Key{})
+		db.Delete(&p)
+	})
+	return NewGormRepo(db), db, p.ID
+}
+
+func newKey(t *testing.T, r Repository, pid uint) *Key {
+	t.Helper()
+	k, err := New("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.ProjectID, k.Environment = pid, "prod"
+	if err := r.Create(&k); err != nil {
+		t.Fatal(err)
+	}
+	return &k
+}
+
+func TestRotateDuringGrace(t *testing.T) {
+	r, db, pid := testRepo(t)
+	k := newKey(t, r, pid)
+
+	second, _ := New("")
+	if ok, err := r.Rotate(k.ID, pid, second, time.Now().Add(time.Hour)); !ok || err != nil {
+		t.Fatalf("first rotation = %v, %v", ok, err)
+	}
+	third, _ := New("")
+	if _, err := r.Rotate(k.ID, pid, third, time.Now().Add(time.Hour)); !errors.Is(err, ErrRotating) {
+		t.Fatalf("rotation during grace: %v, want ErrRotating", err)
+	}
+
+	var got Key
+	db.First(&got, k.ID)
+	if got.Hash != second.Hash || got.PreviousHash != k.Hash {
+		t.Fatal("the refused rotation changed the key: the first secret lost its grace")
+	}
+}
+
+func TestRotateAfterGrace(t *testing.T) {
+	r, db, pid := testRepo(t)
+	k := newKey(t, r, pid)
+
+	second, _ := New("")
+	if ok, err := r.Rotate(k.ID, pid, second, time.Now()); !ok || err != nil { // no grace
+		t.Fatalf("first rotation = %v, %v", ok, err)
+	}
+	third, _ := New("")
+	if ok, err := r.Rotate(k.ID, pid, third, time.Now().Add(time.Hour)); !ok || err != nil {
+		t.Fatalf("rotation after grace = %v, %v", ok, err)
+	}
+	var got Key
+	db.First(&got, k.ID)
+	if got.Hash != third.Hash || got.PreviousHash != second.Hash {
+		t.Fatalf("hashes after two rotations: %+v", got)
+	}
+
+	if ok, err := r.Rotate(k.ID+1000, pid, third, time.Now()); ok || err != nil {
+		t.Fatalf("rotating a missing key = %v, %v; want false, nil", ok, err)
+	}
+}
+
+// Secrets are not a column: nothing read back from the table carries one.
+func TestListHasNoSecrets(t *testing.T) {
+	r, _, pid := testRepo(t)
+	k := newKey(t, r, pid)
+	if k.Secret == "" {
+		t.Fatal("created key has no secret")
+	}
+	ks, err := r.ListByProject(pid)
+	if err != nil || len(ks) != 1 {
+		t.Fatalf("ListByProject = %v, %v", ks, err)
+	}
+	if ks[0].Secret != "" || ks[0].Hash != Hash(k.Secret) {
+		t.Fatalf("listed key %+v", ks[0])
+	}
+}
@@ -0,0 +1,10 @@
+package apikey
+
+import "time"
+
+type Repository interface {
+	Create(*Key) error
+	ListByProject(pid uint) ([]Key, error)
+	Delete(id, pid uint) (bool, error)
+	Rotate(id, pid uint, next Key, graceUntil time.Time) (bool, error)
+}
@@ -0,0 +1,137 @@
+package apikey
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+)
+
+// MaxGrace bounds how long a rotated-out key keeps working.
+const MaxGrace = 30 * 24 * time.Hour
+
+var (
+	ErrNotFound        = errors.New("api key not found")
+	ErrProjectNotFound = org.ErrProjectNotFound
+	ErrForbidden       = org.ErrForbidden
+	ErrUnknownRule     = errors.New("rule does not belong to this project and environment")
+	ErrRotating        = errors.New("key was rotated recently; its previous secret is still in its grace period")
+)
+
+type Service struct {
+	repo      Repository
+	db        *gorm.DB // rule checks
+	authorize org.AuthorizeFunc
+	checkEnv  func(pid uint, env string) error // environment.Check
+	onChange  func(projectID uint)
+}
+
+func NewService(r Repository, db *gorm.DB) *Service {
+	return &Service{
+		repo:      r,
+		db:        db,
+		authorize: org.Authorizer(db),
+		checkEnv:  func(pid uint, env string) error { return environment.Check(db, pid, env) },
+	}
+}
+
+// OnChange registers fn to run after a project's keys changed.
+func (s *Service) OnChange(fn func(projectID uint)) { s.onChange = fn }
+
+func (s *Service) changed(pid uint) {
+	if s.onChange != nil {
+		s.onChange(pid)
+	}
+}
+
+func (s *Service) List(uid, pid uint) ([]Key, error) {
+	if err := s.authorize(pid, uid, org.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByProject(pid)
+}
+
+// Create issues a key for environment env of project pid, optionally
+// restricted to endpoints and rule ids. The returned key carries the
+// secret, which is not stored and cannot be shown again.
+func (s *Service) Create(uid, pid uint, env, name string, endpoints []string, ruleIDs []uint) (*Key, error) {
+	if err := s.authorize(pid, uid, org.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := s.checkEnv(pid, env); err != nil {
+		return nil, err
+	}
+	if len(ruleIDs) > 0 {
+		var n int64
+		if err := s.db.Table("rules").
+			Where("project_id = ? AND environment = ? AND id IN ?", pid, env, ruleIDs).
+			Count(&n).Error; err != nil {
+			return nil, err
+		}
+		if int(n) != len(ruleIDs) {
+			return nil, ErrUnknownRule
+		}
+	}
+	k, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+	k.ProjectID, k.Environment, k.Endpoints, k.RuleIDs = pid, env, endpoints, ruleIDs
+	if err := s.repo.Create(&k); err != nil {
+		return nil, err
+	}
+	s.changed(pid)
+	return &k, nil
+}
+
+func (s *Service) Revoke(uid, pid, id uint) error {
+	if err := s.authorize(pid, uid, org.RoleAdmin); err != nil {
+		return err
+	}
+	ok, err := s.repo.Delete(id, pid)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	s.changed(pid)
+	return nil
+}
+
+// Rotate gives key id a fresh secret and returns it. The old one keeps
+// working until grace has passed, and until then the key can't be rotated
+// again: that would cut off the secret still in its grace period.
+func (s *Service) Rotate(uid, pid, id uint, grace time.Duration) (string, time.Time, error) {
+	if err := s.authorize(pid, uid, org.RoleAdmin); err != nil {
+		return "", time.Time{}, err
+	}
+	next, err := New("")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	until := time.Now().Add(grace)
+	ok, err := s.repo.Rotate(id, pid, next, until)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !ok {
+		return "", time.Time{}, ErrNotFound
+	}
+	s.changed(pid)
+	return next.Secret, until, nil
+}
+
+// DefaultGrace is API_KEY_GRACE_SECONDS, or a day.
+func DefaultGrace() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("API_KEY_GRACE_SECONDS")); err == nil && n >= 0 {
+		return min(time.Duration(n)*time.Second, MaxGrace)
+	}
+	return 24 * time.Hour
+}
@@ -0,0 +1,136 @@
+package apikey
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+// memRepo keeps keys in memory and, like the Postgres repository, refuses
+// to rotate a key whose previous secret is still in its grace period.
+type memRepo struct {
+	keys   []Key
+	nextID uint
+}
+
+func (r *memRepo) Create(k *Key) error {
+	r.nextID++
+	k.ID, k.CreatedAt = r.nextID, time.Now()
+	stored := *k
+	stored.Secret = "" // not a column
+	r.keys = append(r.keys, stored)
+	return nil
+}
+
+func (r *memRepo) ListByProject(pid uint) ([]Key, error) {
+	var ks []Key
+	for _, k := range r.keys {
+		if k.ProjectID == pid {
+			ks = append(ks, k)
+		}
+	}
+	return ks, nil
+}
+
+func (r *memRepo) Delete(id, pid uint) (bool, error) { panic("unused") }
+
+func (r *memRepo) Rotate(id, pid uint, next Key, graceUntil time.Time) (bool, error) {
+	for i := range r.keys {
+		k := &r.keys[i]
+		if k.ID != id || k.ProjectID != pid {
+			continue
+		}
+		if k.PreviousKeyExpiresAt != nil && k.PreviousKeyExpiresAt.After(time.Now()) {
+			return false, ErrRotating
+		}
+		k.PreviousHash, k.PreviousKeyExpiresAt = k.Hash, &graceUntil
+		k.Hash, k.Prefix = next.Hash, next.Prefix
+		return true, nil
+	}
+	return false, nil
+}
+
+const (
+	adminID  uint = 1
+	viewerID uint = 2
+)
+
+func newTestService() (*Service, *memRepo, *[]uint) {
+	r := &memRepo{}
+	s := &Service{
+		repo: r,
+		authorize: func(pid, uid uint, need string) error {
+			if uid == adminID || need == org.RoleViewer {
+				return nil
+			}
+			return org.ErrForbidden
+		},
+		checkEnv: func(pid uint, env string) error {
+			if env != environment.Default {
+				return environment.ErrNotFound
+			}
+			return nil
+		},
+	}
+	var changed []uint
+	s.OnChange(func(pid uint) { changed = append(changed, pid) })
+	return s, r, &changed
+}
+
+func TestRotate(t *testing.T) {
+	s, r, changed := newTestService()
+	k, err := s.Create(adminID, 1, environment.Default, "ci", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.Rotate(viewerID, 1, k.ID, time.Hour); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("viewer rotating: %v, want ErrForbidden", err)
+	}
+	secret, until, err := s.Rotate(adminID, 1, k.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Hash(secret) != r.keys[0].Hash || r.keys[0].PreviousHash != k.Hash {
+		t.Fatal("rotation did not keep the old secret as the previous one")
+	}
+	if time.Until(until) < 59*time.Minute {
+		t.Fatalf("grace ends %v, want in an hour", until)
+	}
+
+	// A second rotation would cut the first secret off before its grace ends.
+	if _, _, err := s.Rotate(adminID, 1, k.ID, time.Hour); !errors.Is(err, ErrRotating) {
+		t.Fatalf("rotating during grace: %v, want ErrRotating", err)
+	}
+	if r.keys[0].PreviousHash != k.Hash {
+		t.Fatal("the refused rotation replaced the previous secret")
+	}
+	if len(*changed) != 2 { // create, first rotation
+		t.Fatalf("OnChange ran %d times, want 2", len(*changed))
+	}
+
+	if _, _, err := s.Rotate(adminID, 1, k.ID+1, time.Hour); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("rotating a missing key: %v, want ErrNotFound", err)
+	}
+}
+
+func TestRotateWithoutGrace(t *testing.T) {
+	s, r, _ := newTestService()
+	k, err := s.Create(adminID, 1, environment.Default, "ci", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Rotate(adminID, 1, k.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := s.Rotate(adminID, 1, k.ID, 0)
+	if err != nil {
+		t.Fatalf("rotating again with no grace left: %v", err)
+	}
+	if Hash(secret) != r.keys[0].Hash {
+		t.Fatal("second rotation did not take effect")
+	}
+}
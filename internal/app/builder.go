@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
@@ -8,15 +9,26 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 
+	"github.com/AliRizaAynaci/rlaas/internal/admin"
+	"github.com/AliRizaAynaci/rlaas/internal/apikey"
 	"github.com/AliRizaAynaci/rlaas/internal/app/health"
 	"github.com/AliRizaAynaci/rlaas/internal/auth"
+	"github.com/AliRizaAynaci/rlaas/internal/changefeed"
 	"github.com/AliRizaAynaci/rlaas/internal/check"
 	"github.com/AliRizaAynaci/rlaas/internal/config"
 	"github.com/AliRizaAynaci/rlaas/internal/database"
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
+	"github.com/AliRizaAynaci/rlaas/internal/invite"
+	"github.com/AliRizaAynaci/rlaas/internal/limiter"
+	"github.com/AliRizaAynaci/rlaas/internal/logging"
+	"github.com/AliRizaAynaci/rlaas/internal/mail"
 	"github.com/AliRizaAynaci/rlaas/internal/middleware"
+	"github.com/AliRizaAynaci/rlaas/internal/org"
 	"github.com/AliRizaAynaci/rlaas/internal/project"
 	"github.com/AliRizaAynaci/rlaas/internal/rule"
 	"github.com/AliRizaAynaci/rlaas/internal/service"
+	"github.com/AliRizaAynaci/rlaas/internal/token"
 	"github.com/AliRizaAynaci/rlaas/internal/user"
 )
 
@@ -31,17 +43,62 @@ func New() *fiber.App {
 
 	if err := database.Migrate(db,
 		&user.User{},
+		&org.Organization{},
+		&org.Member{},
 		&project.Project{},
+		&apikey.Key{},
+		&environment.Environment{},
 		&rule.Rule{},
+		&rule.Version{},
+		&limiter.Counter{},
+		&limiter.ShardNode{},
+		&changefeed.Change{},
+		&token.Token{},
+		&invite.Invitation{},
 	); err != nil {
 		log.Fatalf("db migrate: %v", err)
 	}
+	if err := apikey.MigrateLegacy(db); err != nil {
+		log.Fatalf("api key migrate: %v", err)
+	}
+	if err := environment.MigrateDefault(db); err != nil {
+		log.Fatalf("environment migrate: %v", err)
+	}
+	if err := org.MigrateLegacy(db); err != nil {
+		log.Fatalf("organization migrate: %v", err)
+	}
+	limiter.UsePostgres(db) // counters for LIMITER_BACKEND=postgres, the shard list for redis
+	if err := limiter.ReloadTopology(context.Background()); err != nil {
+		log.Fatalf("shard topology: %v", err)
+	}
 
 	/* ------------ Services ------------ */
 	userSvc := user.NewService(user.NewGormRepo(db))
-	projSvc := project.NewService(project.NewGormRepo(db))
+	orgSvc := org.NewService(org.NewGormRepo(db))
+	projSvc := project.NewService(project.NewGormRepo(db), db, orgSvc)
 	ruleSvc := rule.NewService(rule.NewGormRepo(db), db)
 	rateCfgSvc := service.NewRateConfigService(db)
+	tokenSvc := token.NewService(token.NewGormRepo(db))
+	keySvc := apikey.NewService(apikey.NewGormRepo(db), db)
+	envSvc := environment.NewService(environment.NewGormRepo(db), db)
+	inviteSvc := invite.NewService(invite.NewGormRepo(db), orgSvc, mail.FromEnv())
+	if !inviteSvc.Enabled() {
+		logging.L.Warn("invitations disabled: set INVITE_SECRET to enable them")
+	}
+	projSvc.OnChange(rateCfgSvc.InvalidateProject)
+	ruleSvc.OnChange(rateCfgSvc.InvalidateProject)
+	keySvc.OnChange(rateCfgSvc.InvalidateProject)
+	envSvc.OnChange(rateCfgSvc.InvalidateProject)
+	// changes made on other replicas (and, again, our own); stopped on shutdown
+	feedCtx, stopFeed := context.WithCancel(context.Background())
+	changefeed.Subscribe(feedCtx, db, cfg.DSN, changefeed.Handlers{
+		Project: rateCfgSvc.InvalidateProject,
+		Topology: func() {
+			if err := limiter.ReloadTopology(feedCtx); err != nil {
+				logging.L.Error("shard topology reload", "err", err)
+			}
+		},
+	})
 
 	/* ------------ Handlers ------------ */
 	userHdl := user.NewHandler(userSvc)
@@ -49,9 +106,16 @@ func New() *fiber.App {
 	ruleHdl := rule.NewHandler(ruleSvc)
 	checkH := check.NewHandler(rateCfgSvc)
 	healthH := health.New(db)
+	adminH := admin.NewHandler()
+	tokenH := token.NewHandler(tokenSvc)
+	keyH := apikey.NewHandler(keySvc)
+	envH := environment.NewHandler(envSvc)
+	orgH := org.NewHandler(orgSvc)
+	inviteH := invite.NewHandler(inviteSvc)
 
 	/* ------------ Fiber ------------ */
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: httperr.Handler})
+	app.Hooks().OnShutdown(func() error { stopFeed(); return nil })
 	srv := app.Server()
 	srv.ReadTimeout = 10 * time.Second
 	srv.WriteTimeout = 15 * time.Second
@@ -69,25 +133,81 @@ func New() *fiber.App {
 	app.Get("/readyz", healthH.Readiness) // readiness
 
 	app.Get("/auth/google/login", auth.Login)
-	app.Get("/auth/google/callback", auth.Callback(userSvc))
+	app.Get("/auth/google/callback", auth.Callback(userSvc, inviteSvc))
 	app.Get("/logout", auth.Logout)
 	app.Post("/check", checkH.Handle)
 
+	/* ------------ Operator routes ------------ */
+	ops := app.Group("/admin", middleware.AdminToken())
+	ops.Get("/shards", adminH.ListShards)
+	ops.Post("/shards", adminH.AddShard)
+	ops.Delete("/shards", adminH.DrainShard)
+	ops.Get("/metrics", adminH.Metrics)
+
 	/* ------------ Protected routes ------------ */
-	api := app.Group("/", middleware.Auth())
-	api.Get("/me", userHdl.Me)
+	// personal access tokens are limited to their scopes; sessions are not
+	api := app.Group("/", middleware.Auth(tokenSvc))
+	read := middleware.Scope(token.ScopeRead)
+	rulesWrite := middleware.Scope(token.ScopeRulesWrite)
+	projectsAdmin := middleware.Scope(token.ScopeProjectsAdmin)
+
+	api.Get("/me", read, userHdl.Me)
+
+	/* --- Access tokens (login session only) --- */
+	tokens := api.Group("/tokens", middleware.SessionOnly())
+	tokens.Get("/", tokenH.List)
+	tokens.Post("/", tokenH.Create)
+	tokens.Delete("/:id", tokenH.Revoke)
+
+	/* --- Organizations --- */
+	orgs := api.Group("/orgs")
+	orgs.Get("/", read, orgH.List)
+	orgs.Post("/", projectsAdmin, orgH.Create)
+	orgs.Delete("/:oid", projectsAdmin, orgH.Delete)
+	orgs.Get("/:oid/members", read, orgH.Members)
+	orgs.Post("/:oid/members", projectsAdmin, orgH.AddMember)
+	orgs.Put("/:oid/members/:uid", projectsAdmin, orgH.SetRole)
+	orgs.Delete("/:oid/members/:uid", projectsAdmin, orgH.RemoveMember)
+	orgs.Get("/:oid/invitations", read, inviteH.List)
+	orgs.Post("/:oid/invitations", projectsAdmin, inviteH.Create)
+	orgs.Delete("/:oid/invitations/:iid", projectsAdmin, inviteH.Revoke)
+	api.Post("/invitations/accept", middleware.SessionOnly(), inviteH.Accept)
 
 	/* --- Projects --- */
-	api.Post("/projects", projHdl.Create)
-	api.Get("/projects", projHdl.List)
-	api.Delete("/projects/:pid", projHdl.Delete)
+	api.Post("/projects", projectsAdmin, projHdl.Create)
+	api.Get("/projects", read, projHdl.List)
+	api.Patch("/projects/:pid", projectsAdmin, projHdl.Update)
+	api.Delete("/projects/:pid", projectsAdmin, projHdl.Delete)
+
+	/* --- Environments --- */
+	envs := api.Group("/projects/:pid/environments")
+	envs.Get("/", read, envH.List)
+	envs.Post("/", projectsAdmin, envH.Create)
+	envs.Delete("/:env", projectsAdmin, envH.Delete)
+	envs.Get("/:env/drift", read, ruleHdl.Drift)
+	envs.Post("/:env/promote", rulesWrite, ruleHdl.Promote)
+
+	/* --- API keys --- */
+	keys := api.Group("/projects/:pid/api-keys")
+	keys.Get("/", read, keyH.List)
+	keys.Post("/", projectsAdmin, keyH.Create)
+	keys.Delete("/:kid", projectsAdmin, keyH.Revoke)
+	keys.Post("/:kid/rotate", projectsAdmin, keyH.Rotate)
+
+	api.Get("/projects/:pid/keys/state", read, checkH.KeyState)
+
+	/* --- Declarative config --- */
+	api.Get("/projects/:pid/config", read, ruleHdl.ExportConfig)
+	api.Put("/projects/:pid/config", rulesWrite, ruleHdl.ApplyConfig)
 
 	/* --- Nested Rules --- */
 	rules := api.Group("/projects/:pid/rules")
-	rules.Get("/", ruleHdl.List)
-	rules.Post("/", ruleHdl.Create)
-	rules.Put("/:rid", ruleHdl.Update)
-	rules.Delete("/:rid", ruleHdl.Delete)
+	rules.Get("/", read, ruleHdl.List)
+	rules.Post("/", rulesWrite, ruleHdl.Create)
+	rules.Put("/:rid", rulesWrite, ruleHdl.Update)
+	rules.Delete("/:rid", rulesWrite, ruleHdl.Delete)
+	rules.Get("/:rid/versions", read, ruleHdl.Versions)
+	rules.Post("/:rid/versions/:ver/rollback", rulesWrite, ruleHdl.Rollback)
 
 	return app
 }
@@ -6,6 +6,8 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/limiter"
 )
 
 // Handler holds slow-changing deps we want to ping.
@@ -28,5 +30,21 @@ func (h *Handler) Readiness(c *fiber.Ctx) error {
 	if err := h.db.WithContext(ctx).Raw("SELECT 1").Error; err != nil {
 		return fiber.ErrServiceUnavailable
 	}
-	return c.JSON(fiber.Map{"status": "ready"})
+
+	// shards: degraded while any is down, unavailable when all are
+	shards := limiter.ShardHealth()
+	up := 0
+	for _, s := range shards {
+		if s.Healthy {
+			up++
+		}
+	}
+	switch {
+	case up == 0:
+		return c.Status(fiber.StatusServiceUnavailable).
+			JSON(fiber.Map{"status": "unavailable", "shards": shards})
+	case up < len(shards):
+		return c.JSON(fiber.Map{"status": "degraded", "shards": shards})
+	}
+	return c.JSON(fiber.Map{"status": "ready", "shards": shards})
 }
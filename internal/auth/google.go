@@ -13,6 +13,8 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 
+	"github.com/AliRizaAynaci/rlaas/internal/invite"
+	"github.com/AliRizaAynaci/rlaas/internal/logging"
 	"github.com/AliRizaAynaci/rlaas/internal/user"
 )
 
@@ -48,14 +50,30 @@ func cfg() *oauth2.Config {
 /*  Handlers                                                              */
 /* ---------------------------------------------------------------------- */
 
-// GET /auth/google/login
+// inviteCookie holds an invitation token across the Google round trip.
+const inviteCookie = "invite_token"
+
+// GET /auth/google/login[?invite=<token>]
 func Login(c *fiber.Ctx) error {
+	if inv := c.Query("invite"); inv != "" {
+		c.Cookie(&fiber.Cookie{
+			Name:     inviteCookie,
+			Value:    inv,
+			Path:     "/auth/google",
+			MaxAge:   15 * 60,
+			HTTPOnly: true,
+			SameSite: "Lax", // sent on the redirect back from Google
+			Secure:   true,
+		})
+	}
 	url := cfg().AuthCodeURL("state-token", oauth2.AccessTypeOffline)
 	return c.Redirect(url, fiber.StatusTemporaryRedirect)
 }
 
 // GET /auth/google/callback
-func Callback(svc *user.Service) fiber.Handler {
+// An invitation from Login is accepted for the signed-in user; failing
+// to accept it doesn't fail the sign-in.
+func Callback(svc *user.Service, invites *invite.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		code := c.Query("code")
 		if code == "" {
@@ -91,6 +109,14 @@ func Callback(svc *user.Service) fiber.Handler {
 			return fiber.NewError(fiber.StatusInternalServerError, "user create: "+err.Error())
 		}
 
+		// Link a pending invitation to the user
+		if inv := c.Cookies(inviteCookie); inv != "" {
+			c.Cookie(&fiber.Cookie{Name: inviteCookie, Path: "/auth/google", MaxAge: -1, HTTPOnly: true, Secure: true})
+			if _, err := invites.Accept(u.ID, inv); err != nil {
+				logging.L.Warn("invitation not accepted", "user_id", u.ID, "err", err)
+			}
+		}
+
 		// Issue JWT
 		claims := jwt.MapClaims{
 			"user_id": u.ID,
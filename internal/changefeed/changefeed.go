@@ -0,0 +1,267 @@
+// Package changefeed tells every rlaas instance when a project's rate-limit
+// config or the shard topology changed, so each can drop what it has cached.
+//
+// Writers call Publish inside the transaction that changes the config. It
+// appends a row to the config_changes outbox and issues pg_notify; both take
+// effect only if the transaction commits. Subscribers LISTEN on Channel and
+// read the outbox when notified. When LISTEN isn't possible (no direct
+// connection, e.g. behind a transaction-mode pooler) they poll the outbox
+// every CONFIG_POLL_MS instead.
+package changefeed
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/logging"
+)
+
+// Channel is the NOTIFY channel change events are sent on.
+const Channel = "rlaas_config"
+
+// Outbox rows are kept this long, so a briefly disconnected instance can
+// still catch up by polling.
+const retention = time.Hour
+
+// IDs are taken when a row is inserted but become visible on commit, so a
+// reader can see ID 7 before 6. A missing ID holds the cursor back this
+// long, which bounds how long a transaction may sit between its Publish
+// and its commit; after that the ID is taken to be rolled back.
+const gapGrace = time.Minute
+
+// Change kinds.
+const (
+	KindProject  = "project"  // a project's rules, keys or environments
+	KindTopology = "topology" // the shard list
+)
+
+// Change is one outbox row: the config of ProjectID changed, or for
+// KindTopology the shard list did.
+type Change struct {
+	ID        uint64    `gorm:"primaryKey"`
+	Kind      string    `gorm:"not null;default:project"`
+	ProjectID uint      `gorm:"not null"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (Change) TableName() string { return "config_changes" }
+
+// Publish records a change of project pid as part of tx.
+func Publish(tx *gorm.DB, pid uint) error {
+	if err := tx.Create(&Change{Kind: KindProject, ProjectID: pid}).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`SELECT pg_notify(?, ?)`, Channel, strconv.FormatUint(uint64(pid), 10)).Error
+}
+
+// PublishTopology records a change of the shard list as part of tx.
+func PublishTopology(tx *gorm.DB) error {
+	if err := tx.Create(&Change{Kind: KindTopology}).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`SELECT pg_notify(?, ?)`, Channel, KindTopology).Error
+}
+
+// Handlers are called by Subscribe, one per kind of change.
+type Handlers struct {
+	Project  func(projectID uint)
+	Topology func()
+}
+
+// Subscribe calls the handler in h for every change published by any
+// instance (this one included) until ctx is done. dsn is used for the
+// dedicated LISTEN connection.
+//
+// Notifications carry the project ID (or "topology") and are acted on
+// directly. The outbox is read as well, to catch changes made while LISTEN
+// was down; see gapGrace for rows that commit out of order.
+func Subscribe(ctx context.Context, db *gorm.DB, dsn string, h Handlers) {
+	s := newSubscriber(db, h)
+	s.cursor = s.head(ctx)
+
+	go s.listen(ctx, dsn)
+	go s.poll(ctx, time.Duration(envInt("CONFIG_POLL_MS", 1000))*time.Millisecond)
+}
+
+type subscriber struct {
+	db   *gorm.DB
+	h    Handlers
+	wake chan struct{}
+
+	// owned by poll
+	cursor uint64               // every ID up to here is handled or given up
+	seen   map[uint64]bool      // handled IDs above cursor
+	gaps   map[uint64]time.Time // missing IDs above cursor, and since when
+
+	listening atomic.Bool
+}
+
+func newSubscriber(db *gorm.DB, h Handlers) *subscriber {
+	return &subscriber{
+		db:   db,
+		h:    h,
+		wake: make(chan struct{}, 1),
+		seen: make(map[uint64]bool),
+		gaps: make(map[uint64]time.Time),
+	}
+}
+
+// head returns the newest outbox ID; older changes predate our caches.
+func (s *subscriber) head(ctx context.Context) uint64 {
+	var id uint64
+	s.db.WithContext(ctx).Raw(`SELECT COALESCE(MAX(id), 0) FROM config_changes`).Scan(&id)
+	return id
+}
+
+// listen keeps a LISTEN connection open, reconnecting with backoff.
+func (s *subscriber) listen(ctx context.Context, dsn string) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := s.listenOnce(ctx, dsn)
+		s.listening.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		logging.L.Warn("config LISTEN unavailable, polling", "err", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, time.Minute)
+	}
+}
+
+func (s *subscriber) listenOnce(ctx context.Context, dsn string) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, `LISTEN `+Channel); err != nil {
+		return err
+	}
+	s.listening.Store(true)
+	s.signal() // catch up on anything published while we were connecting
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if n.Payload == KindTopology {
+			s.h.Topology()
+		} else if pid, err := strconv.ParseUint(n.Payload, 10, 0); err == nil {
+			s.h.Project(uint(pid))
+		}
+		s.signal()
+	}
+}
+
+func (s *subscriber) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// poll reads the outbox when woken by a notification, on every tick while
+// LISTEN is down, and every 30s regardless as a safety net.
+func (s *subscriber) poll(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+
+	var lastRead, lastPrune time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-t.C:
+			if s.listening.Load() && time.Since(lastRead) < 30*time.Second {
+				continue
+			}
+		}
+		s.read(ctx)
+		lastRead = time.Now()
+
+		if time.Since(lastPrune) > retention/4 {
+			s.db.WithContext(ctx).Where("created_at < ?", time.Now().Add(-retention)).Delete(&Change{})
+			lastPrune = time.Now()
+		}
+	}
+}
+
+func (s *subscriber) read(ctx context.Context) {
+	var rows []Change
+	if err := s.db.WithContext(ctx).Where("id > ?", s.cursor).
+		Order("id").Limit(1000).Find(&rows).Error; err != nil {
+		return
+	}
+	if s.handle(rows, time.Now()) && len(rows) == 1000 {
+		s.signal() // more to read
+	}
+}
+
+// handle acts on the rows not handled yet, once per project, and moves the
+// cursor up to the first ID that may still commit. It reports whether any
+// row was new.
+func (s *subscriber) handle(rows []Change, now time.Time) bool {
+	projects := make(map[uint]bool)
+	fresh, topology := false, false
+	for _, r := range rows {
+		if r.ID <= s.cursor || s.seen[r.ID] {
+			continue
+		}
+		s.seen[r.ID] = true
+		delete(s.gaps, r.ID)
+		fresh = true
+
+		switch {
+		case r.Kind == KindTopology:
+			if !topology {
+				topology = true
+				s.h.Topology()
+			}
+		case !projects[r.ProjectID]:
+			projects[r.ProjectID] = true
+			s.h.Project(r.ProjectID)
+		}
+	}
+
+	var top uint64
+	for id := range s.seen {
+		top = max(top, id)
+	}
+	for id := s.cursor + 1; id < top; id++ {
+		if _, ok := s.gaps[id]; !ok && !s.seen[id] {
+			s.gaps[id] = now
+		}
+	}
+	for {
+		next := s.cursor + 1
+		if since, ok := s.gaps[next]; ok && now.Sub(since) >= gapGrace {
+			delete(s.gaps, next)
+		} else if s.seen[next] {
+			delete(s.seen, next)
+		} else {
+			break
+		}
+		s.cursor = next
+	}
+	return fresh
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,157 @@
+package changefeed
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type recorder struct {
+	projects []uint
+	topology int
+}
+
+func (r *recorder) handlers() Handlers {
+	return Handlers{
+		Project:  func(pid uint) { r.projects = append(r.projects, pid) },
+		Topology: func() { r.topology++ },
+	}
+}
+
+func row(id uint64, pid uint) Change { return Change{ID: id, Kind: KindProject, ProjectID: pid} }
+
+func TestHandleOncePerProjectAndKind(t *testing.T) {
+	var r recorder
+	s := newSubscriber(nil, r.handlers())
+	s.handle([]Change{row(1, 7), row(2, 7), row(3, 8), {ID: 4, Kind: KindTopology}, {ID: 5, Kind: KindTopology}}, time.Now())
+
+	if len(r.projects) != 2 || r.projects[0] != 7 || r.projects[1] != 8 {
+		t.Fatalf("projects = %v, want [7 8]", r.projects)
+	}
+	if r.topology != 1 {
+		t.Fatalf("topology handled %d times, want 1", r.topology)
+	}
+	if s.cursor != 5 {
+		t.Fatalf("cursor = %d, want 5", s.cursor)
+	}
+}
+
+// A row that commits after a higher-numbered one is still handled.
+func TestHandleWaitsForOutOfOrderCommit(t *testing.T) {
+	var r recorder
+	s := newSubscriber(nil, r.handlers())
+	now := time.Now()
+
+	s.handle([]Change{row(1, 1), row(3, 3)}, now)
+	if s.cursor != 1 {
+		t.Fatalf("cursor = %d, want 1: ID 2 may still commit", s.cursor)
+	}
+	// the next read starts above the cursor, so it sees 3 again
+	if !s.handle([]Change{row(2, 2), row(3, 3)}, now.Add(time.Second)) {
+		t.Fatal("the late row was not reported as new")
+	}
+	if len(r.projects) != 3 || r.projects[2] != 2 {
+		t.Fatalf("projects = %v, want [1 3 2]", r.projects)
+	}
+	if s.cursor != 3 || len(s.seen) != 0 || len(s.gaps) != 0 {
+		t.Fatalf("cursor = %d, seen %v, gaps %v; want 3 and nothing pending", s.cursor, s.seen, s.gaps)
+	}
+}
+
+// A gap that never fills (a rolled-back transaction) stops holding the
+// cursor after gapGrace.
+func TestHandleGivesUpOnGapAfterGrace(t *testing.T) {
+	var r recorder
+	s := newSubscriber(nil, r.handlers())
+	now := time.Now()
+
+	s.handle([]Change{row(1, 1), row(3, 3)}, now)
+	if s.handle([]Change{row(3, 3)}, now.Add(gapGrace/2)) {
+		t.Fatal("re-read row reported as new")
+	}
+	if s.cursor != 1 {
+		t.Fatalf("cursor = %d before the grace ran out, want 1", s.cursor)
+	}
+	s.handle([]Change{row(3, 3)}, now.Add(gapGrace))
+	if s.cursor != 3 {
+		t.Fatalf("cursor = %d after the grace, want 3", s.cursor)
+	}
+	if len(r.projects) != 2 {
+		t.Fatalf("projects = %v, want each handled once", r.projects)
+	}
+}
+
+// testDB needs a scratch database in LIMITER_TEST_POSTGRES_DSN, the same one
+// the limiter's Postgres tests use.
+func testDB(t *testing.T) (*gorm.DB, string) {
+	dsn := os.Getenv("LIMITER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("LIMITER_TEST_POSTGRES_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&Change{}); err != nil {
+		t.Fatal(err)
+	}
+	return db, dsn
+}
+
+// subscribe runs Subscribe and returns the projects it reports.
+func subscribe(t *testing.T, db *gorm.DB, dsn string) <-chan uint {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	got := make(chan uint, 100)
+	Subscribe(ctx, db, dsn, Handlers{
+		Project:  func(pid uint) { got <- pid },
+		Topology: func() {},
+	})
+	return got
+}
+
+func waitFor(t *testing.T, got <-chan uint, pid uint, within time.Duration) {
+	t.Helper()
+	deadline := time.After(within)
+	for {
+		select {
+		case p := <-got:
+			if p == pid {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("project %d not reported within %s", pid, within)
+		}
+	}
+}
+
+func publish(t *testing.T, db *gorm.DB) uint {
+	pid := uint(time.Now().UnixNano() % 1e9)
+	if err := db.Transaction(func(tx *gorm.DB) error { return Publish(tx, pid) }); err != nil {
+		t.Fatal(err)
+	}
+	return pid
+}
+
+func TestSubscribeListen(t *testing.T) {
+	db, dsn := testDB(t)
+	t.Setenv("CONFIG_POLL_MS", "60000") // only LISTEN can deliver in time
+	got := subscribe(t, db, dsn)
+	time.Sleep(200 * time.Millisecond) // let LISTEN connect
+
+	waitFor(t, got, publish(t, db), 2*time.Second)
+}
+
+// With LISTEN unavailable, changes still arrive by polling.
+func TestSubscribePollingFallback(t *testing.T) {
+	db, _ := testDB(t)
+	t.Setenv("CONFIG_POLL_MS", "20")
+	got := subscribe(t, db, "host=127.0.0.1 port=1 connect_timeout=1")
+
+	waitFor(t, got, publish(t, db), 2*time.Second)
+}
@@ -1,8 +1,12 @@
 package check
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
 	"github.com/AliRizaAynaci/rlaas/internal/limiter"
 	"github.com/AliRizaAynaci/rlaas/internal/service"
 )
@@ -21,22 +25,71 @@ func (h *Handler) Handle(c *fiber.Ctx) error {
 		return fiber.ErrBadRequest
 	}
 
-	cfg, err := h.svc.Get(req.APIKey, req.Endpoint)
+	ctx := c.Context()
+	cfgs, err := h.svc.GetAll(ctx, req.APIKey, req.Endpoint)
 	switch err {
 	case service.ErrProjectNotFound:
 		return fiber.ErrUnauthorized
-	case service.ErrEndpointNotOwned:
+	case service.ErrEndpointNotOwned, service.ErrKeyNotAllowed:
 		return fiber.ErrForbidden
 	case nil:
 	default:
 		return fiber.ErrInternalServerError
 	}
 
-	lim, _ := limiter.GetLimiterForKey(req.APIKey, req.Endpoint, req.Key, cfg)
-	allowed, _ := lim.Allow(req.Key)
-	if !allowed {
-		return fiber.NewError(fiber.StatusTooManyRequests, "Rate limit exceeded")
+	// one rule or several, the same counters: adding or removing a rule
+	// leaves the others' state alone
+	d := limiter.AllowAll(ctx, cfgs[0].Namespace, req.Endpoint, req.Key, cfgs)
+	c.Locals("reason", d.Reason)
+
+	// backend failures are resolved by the rule's fail mode; tell the caller
+	res := fiber.Map{"allowed": d.Allowed, "reason": d.Reason}
+	if d.TimedOut() {
+		res["timeout"] = true
+	}
+	if !d.Allowed {
+		res["error"] = "Rate limit exceeded"
+		if d.Reason == limiter.ReasonFailClosed {
+			res["error"] = "Rate limiter unavailable"
+		}
+		return c.Status(fiber.StatusTooManyRequests).JSON(res)
 	}
+	return c.JSON(res)
+}
 
-	return c.JSON(fiber.Map{"allowed": true})
+// GET /projects/:pid/keys/state?endpoint=/x&key=u&env=prod
+// Reports each rule's remaining quota for a key without consuming any.
+func (h *Handler) KeyState(c *fiber.Ctx) error {
+	pid, _ := c.ParamsInt("pid")
+	endpoint, key := c.Query("endpoint"), c.Query("key")
+	bad := make(map[string]string)
+	if endpoint == "" {
+		bad["endpoint"] = "is required"
+	}
+	if key == "" {
+		bad["key"] = "is required"
+	}
+	if len(bad) > 0 {
+		return httperr.Invalid(bad)
+	}
+
+	ctx := c.Context()
+	env := c.Query("env", environment.Default)
+	cfgs, err := h.svc.ProjectConfigs(ctx, uint(pid), c.Locals("user_id").(uint), env, endpoint)
+	switch {
+	case errors.Is(err, service.ErrProjectNotFound):
+		return httperr.NotFound("project not found")
+	case errors.Is(err, service.ErrForbidden):
+		return httperr.Forbidden(err.Error())
+	case errors.Is(err, service.ErrEndpointNotOwned):
+		return httperr.NotFound("no rules for endpoint")
+	case err != nil:
+		return httperr.Internal(err)
+	}
+
+	rules, err := limiter.Inspect(ctx, cfgs[0].Namespace, endpoint, key, cfgs)
+	if err != nil {
+		return httperr.New(fiber.StatusServiceUnavailable, "backend_unavailable", err.Error())
+	}
+	return c.JSON(fiber.Map{"endpoint": endpoint, "key": key, "rules": rules})
 }
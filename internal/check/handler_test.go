@@ -0,0 +1,138 @@
+package check
+
+import (
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/AliRizaAynaci/rlaas/internal/apikey"
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+	"github.com/AliRizaAynaci/rlaas/internal/limiter"
+	"github.com/AliRizaAynaci/rlaas/internal/project"
+	"github.com/AliRizaAynaci/rlaas/internal/rule"
+	"github.com/AliRizaAynaci/rlaas/internal/service"
+)
+
+// testProject needs a scratch database in LIMITER_TEST_POSTGRES_DSN, e.g.
+// the docker-compose Postgres. It creates a project with these rules:
+//
+//	prod    /pay    limit 1, and limit 100
+//	prod    /other  limit 100
+//	staging /pay    limit 1
+func testProject(t *testing.T) (*gorm.DB, *project.Project) {
+	dsn := os.Getenv("LIMITER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("LIMITER_TEST_POSTGRES_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&project.Project{}, &rule.Rule{}, &apikey.Key{}, &environment.Environment{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LIMITER_BACKEND", limiter.BackendMemory)
+	if err := limiter.InitSharding(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := func(env, endpoint string, limit int) rule.Rule {
+		return rule.Rule{Environment: env, Endpoint: endpoint, Strategy: "fixed_window", KeyBy: "ip",
+			LimitCount: limit, WindowSeconds: 3600, FailMode: "closed", Consistency: "strict"}
+	}
+	p := &project.Project{
+		Name:       "check-test",
+		CounterKey: "check-test-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		Rules: []rule.Rule{
+			r(environment.Default, "/pay", 1),
+			r(environment.Default, "/pay", 100),
+			r(environment.Default, "/other", 100),
+			r("staging", "/pay", 1),
+		},
+		Environments: []environment.Environment{{Name: environment.Default}, {Name: "staging"}},
+	}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("project_id = ?", p.ID).Delete(&apikey.Key{})
+		db.Where("project_id = ?", p.ID).Delete(&rule.Rule{})
+		db.Where("project_id = ?", p.ID).Delete(&environment.Environment{})
+		db.Delete(p)
+	})
+	return db, p
+}
+
+// key stores an API key for p and returns its secret.
+func key(t *testing.T, db *gorm.DB, p *project.Project, env string, endpoints []string, ruleIDs []uint) string {
+	t.Helper()
+	k, err := apikey.New("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.ProjectID, k.Environment, k.Endpoints, k.RuleIDs = p.ID, env, endpoints, ruleIDs
+	if err := db.Create(&k).Error; err != nil {
+		t.Fatal(err)
+	}
+	return k.Secret
+}
+
+func check(t *testing.T, app *fiber.App, apiKey, endpoint, client string) int {
+	t.Helper()
+	body := `{"api_key":"` + apiKey + `","endpoint":"` + endpoint + `","key":"` + client + `"}`
+	req := httptest.NewRequest("POST", "/check", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func TestCheckEnforcesKeyRestrictions(t *testing.T) {
+	db, p := testProject(t)
+	app := fiber.New()
+	app.Post("/check", NewHandler(service.NewRateConfigService(db)).Handle)
+
+	all := key(t, db, p, environment.Default, nil, nil)
+	payOnly := key(t, db, p, environment.Default, []string{"/pay"}, nil)
+	looseRule := key(t, db, p, environment.Default, nil, []uint{p.Rules[1].ID})
+	staging := key(t, db, p, "staging", nil, nil)
+
+	steps := []struct {
+		name                  string
+		key, endpoint, client string
+		want                  int
+	}{
+		{"unknown key", "rlaas_key_nope", "/pay", "a", fiber.StatusUnauthorized},
+
+		{"endpoint-restricted key, its endpoint", payOnly, "/pay", "b", fiber.StatusOK},
+		{"endpoint-restricted key, another endpoint", payOnly, "/other", "b", fiber.StatusForbidden},
+		{"unrestricted key, same endpoint", all, "/other", "b", fiber.StatusOK},
+
+		// looseRule skips the limit-1 rule on /pay
+		{"rule-restricted key", looseRule, "/pay", "c", fiber.StatusOK},
+		{"rule-restricted key again", looseRule, "/pay", "c", fiber.StatusOK},
+		{"rule-restricted key, no allowed rule", looseRule, "/other", "c", fiber.StatusForbidden},
+		{"unrestricted key", all, "/pay", "d", fiber.StatusOK},
+		{"unrestricted key over limit 1", all, "/pay", "d", fiber.StatusTooManyRequests},
+
+		// staging's rules and counters are its own
+		{"staging, prod used up", staging, "/pay", "d", fiber.StatusOK},
+		{"staging over its limit", staging, "/pay", "d", fiber.StatusTooManyRequests},
+		{"staging, endpoint with only prod rules", staging, "/other", "d", fiber.StatusForbidden},
+	}
+	for _, s := range steps {
+		if got := check(t, app, s.key, s.endpoint, s.client); got != s.want {
+			t.Fatalf("%s: %d, want %d", s.name, got, s.want)
+		}
+	}
+}
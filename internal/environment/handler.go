@@ -0,0 +1,65 @@
+package environment
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
+)
+
+type Handler struct{ svc *Service }
+
+func NewHandler(s *Service) *Handler { return &Handler{s} }
+
+func pid(c *fiber.Ctx) uint { id, _ := strconv.Atoi(c.Params("pid")); return uint(id) }
+
+// apiError maps service errors onto the API error envelope.
+func apiError(err error) error {
+	switch {
+	case errors.Is(err, ErrProjectNotFound):
+		return httperr.NotFound("project not found")
+	case errors.Is(err, ErrNotFound):
+		return httperr.NotFound(err.Error())
+	case errors.Is(err, ErrForbidden):
+		return httperr.Forbidden(err.Error())
+	case errors.Is(err, ErrExists), errors.Is(err, ErrInUse), errors.Is(err, ErrDefault):
+		return httperr.Conflict(err.Error())
+	case errors.Is(err, ErrInvalidName):
+		return httperr.Invalid(map[string]string{"name": err.Error()})
+	}
+	return httperr.Internal(err)
+}
+
+// GET /projects/:pid/environments
+func (h *Handler) List(c *fiber.Ctx) error {
+	es, err := h.svc.List(c.Locals("user_id").(uint), pid(c))
+	if err != nil {
+		return apiError(err)
+	}
+	return c.JSON(es)
+}
+
+// POST /projects/:pid/environments  { "name": "staging" }
+func (h *Handler) Create(c *fiber.Ctx) error {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return httperr.BadRequest("body is not valid JSON")
+	}
+	e, err := h.svc.Create(c.Locals("user_id").(uint), pid(c), req.Name)
+	if err != nil {
+		return apiError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(e)
+}
+
+// DELETE /projects/:pid/environments/:env
+func (h *Handler) Delete(c *fiber.Ctx) error {
+	if err := h.svc.Delete(c.Locals("user_id").(uint), pid(c), c.Params("env")); err != nil {
+		return apiError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,21 @@
+package environment
+
+import (
+	"regexp"
+	"time"
+)
+
+// Default is the environment every project starts with; rules and keys
+// that don't name one belong to it.
+const Default = "prod"
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// Environment is a named rule set within a project (e.g. staging, prod),
+// with its own API keys.
+type Environment struct {
+	ID        uint      `json:"id"         gorm:"primaryKey"`
+	ProjectID uint      `json:"project_id" gorm:"uniqueIndex:idx_env_project_name;not null"`
+	Name      string    `json:"name"       gorm:"uniqueIndex:idx_env_project_name;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
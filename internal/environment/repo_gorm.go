@@ -0,0 +1,74 @@
+package environment
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/AliRizaAynaci/rlaas/internal/changefeed"
+)
+
+type gormRepo struct{ db *gorm.DB }
+
+func NewGormRepo(db *gorm.DB) Repository { return &gormRepo{db} }
+
+func (r *gormRepo) Create(e *Environment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(e)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrExists // a concurrent create of the same name won
+		}
+		return changefeed.Publish(tx, e.ProjectID)
+	})
+}
+
+func (r *gormRepo) ListByProject(pid uint) ([]Environment, error) {
+	var es []Environment
+	return es, r.db.Where("project_id = ?", pid).Order("id").Find(&es).Error
+}
+
+func (r *gormRepo) Find(pid uint, name string) (*Environment, error) {
+	var e Environment
+	return &e, r.db.Where("project_id = ? AND name = ?", pid, name).First(&e).Error
+}
+
+func (r *gormRepo) InUse(pid uint, name string) (bool, error) {
+	var used bool
+	return used, r.db.Raw(`SELECT
+			EXISTS (SELECT 1 FROM rules    WHERE project_id = @pid AND environment = @name) OR
+			EXISTS (SELECT 1 FROM api_keys WHERE project_id = @pid AND environment = @name)`,
+		map[string]any{"pid": pid, "name": name}).Scan(&used).Error
+}
+
+func (r *gormRepo) Delete(e *Environment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&Environment{}, e.ID).Error; err != nil {
+			return err
+		}
+		return changefeed.Publish(tx, e.ProjectID)
+	})
+}
+
+// MigrateDefault gives every project without environments the Default one.
+func MigrateDefault(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Environment{}) {
+		return nil
+	}
+	return db.Exec(`INSERT INTO environments (project_id, name, created_at)
+		SELECT p.id, ?, NOW() FROM projects p
+		WHERE NOT EXISTS (SELECT 1 FROM environments e WHERE e.project_id = p.id)`, Default).Error
+}
+
+// Check returns ErrNotFound unless project pid has environment name.
+func Check(db *gorm.DB, pid uint, name string) error {
+	var n int64
+	if err := db.Model(&Environment{}).Where("project_id = ? AND name = ?", pid, name).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
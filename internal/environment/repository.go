@@ -0,0 +1,11 @@
+package environment
+
+type Repository interface {
+	// Create returns ErrExists if the project already has the name.
+	Create(*Environment) error
+	ListByProject(pid uint) ([]Environment, error)
+	Find(pid uint, name string) (*Environment, error)
+	// InUse reports whether any rule or API key belongs to the environment.
+	InUse(pid uint, name string) (bool, error)
+	Delete(*Environment) error
+}
@@ -0,0 +1,89 @@
+package environment
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+var (
+	ErrNotFound        = errors.New("environment not found")
+	ErrExists          = errors.New("environment already exists")
+	ErrInUse           = errors.New("environment still has rules or API keys")
+	ErrDefault         = errors.New("the " + Default + " environment cannot be deleted")
+	ErrInvalidName     = errors.New("name must be 1-32 lowercase letters, digits or dashes")
+	ErrProjectNotFound = org.ErrProjectNotFound
+	ErrForbidden       = org.ErrForbidden
+)
+
+type Service struct {
+	repo      Repository
+	authorize org.AuthorizeFunc
+	onChange  func(projectID uint)
+}
+
+func NewService(r Repository, db *gorm.DB) *Service {
+	return &Service{repo: r, authorize: org.Authorizer(db)}
+}
+
+// OnChange registers fn to run after a project's environments changed.
+func (s *Service) OnChange(fn func(projectID uint)) { s.onChange = fn }
+
+func (s *Service) changed(pid uint) {
+	if s.onChange != nil {
+		s.onChange(pid)
+	}
+}
+
+func (s *Service) List(uid, pid uint) ([]Environment, error) {
+	if err := s.authorize(pid, uid, org.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByProject(pid)
+}
+
+func (s *Service) Create(uid, pid uint, name string) (*Environment, error) {
+	if err := s.authorize(pid, uid, org.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if !validName.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+	e := &Environment{ProjectID: pid, Name: name}
+	if err := s.repo.Create(e); err != nil {
+		return nil, err
+	}
+	s.changed(pid)
+	return e, nil
+}
+
+// Delete removes an empty environment other than Default.
+func (s *Service) Delete(uid, pid uint, name string) error {
+	if err := s.authorize(pid, uid, org.RoleAdmin); err != nil {
+		return err
+	}
+	if name == Default {
+		return ErrDefault
+	}
+	e, err := s.repo.Find(pid, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	used, err := s.repo.InUse(pid, name)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrInUse
+	}
+	if err := s.repo.Delete(e); err != nil {
+		return err
+	}
+	s.changed(pid)
+	return nil
+}
@@ -0,0 +1,115 @@
+package environment
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+// memRepo holds one project's environments; used names are in use.
+type memRepo struct {
+	envs []Environment
+	used map[string]bool
+}
+
+func (r *memRepo) Create(e *Environment) error {
+	if _, err := r.Find(e.ProjectID, e.Name); err == nil {
+		return ErrExists
+	}
+	e.ID = uint(len(r.envs) + 1)
+	r.envs = append(r.envs, *e)
+	return nil
+}
+
+func (r *memRepo) ListByProject(pid uint) ([]Environment, error) { return r.envs, nil }
+
+func (r *memRepo) Find(pid uint, name string) (*Environment, error) {
+	for _, e := range r.envs {
+		if e.Name == name {
+			return &e, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memRepo) InUse(pid uint, name string) (bool, error) { return r.used[name], nil }
+
+func (r *memRepo) Delete(e *Environment) error {
+	for i := range r.envs {
+		if r.envs[i].ID == e.ID {
+			r.envs = append(r.envs[:i], r.envs[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// newTestService lets user 1 do anything and user 2 only read.
+func newTestService() (*Service, *memRepo, *[]uint) {
+	r := &memRepo{
+		envs: []Environment{{ID: 1, ProjectID: 7, Name: Default}, {ID: 2, ProjectID: 7, Name: "staging"}, {ID: 3, ProjectID: 7, Name: "dev"}},
+		used: map[string]bool{"staging": true},
+	}
+	var changed []uint
+	s := &Service{repo: r, authorize: func(pid, uid uint, need string) error {
+		if uid == 1 || need == org.RoleViewer {
+			return nil
+		}
+		return ErrForbidden
+	}}
+	s.OnChange(func(pid uint) { changed = append(changed, pid) })
+	return s, r, &changed
+}
+
+func TestDeleteRules(t *testing.T) {
+	tests := []struct {
+		name string
+		uid  uint
+		env  string
+		want error
+	}{
+		{"default", 1, Default, ErrDefault},
+		{"missing", 1, "qa", ErrNotFound},
+		{"has rules or keys", 1, "staging", ErrInUse},
+		{"viewer", 2, "dev", ErrForbidden},
+		{"empty", 1, "dev", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, r, changed := newTestService()
+			err := s.Delete(tc.uid, 7, tc.env)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+			if left := len(r.envs); (left == 2) != (err == nil) {
+				t.Fatalf("%d environments left after err %v", left, err)
+			}
+			if (len(*changed) == 1) != (err == nil) {
+				t.Fatalf("change hook ran %d times after err %v", len(*changed), err)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	s, _, changed := newTestService()
+	if _, err := s.Create(1, 7, "Bad Name"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("invalid name: %v", err)
+	}
+	if _, err := s.Create(1, 7, "staging"); !errors.Is(err, ErrExists) {
+		t.Fatalf("existing name: %v, want ErrExists", err)
+	}
+	if _, err := s.Create(2, 7, "qa"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("viewer: %v, want ErrForbidden", err)
+	}
+	e, err := s.Create(1, 7, "qa")
+	if err != nil || e.Name != "qa" || e.ProjectID != 7 {
+		t.Fatalf("create = %+v, %v", e, err)
+	}
+	if len(*changed) != 1 {
+		t.Fatalf("change hook ran %d times, want once", len(*changed))
+	}
+}
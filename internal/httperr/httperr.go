@@ -0,0 +1,66 @@
+// Package httperr renders API errors in one JSON envelope:
+//
+//	{"error": {"code": "validation_failed", "message": "…", "fields": {"limit_count": "must be positive"}}}
+package httperr
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Error is an API error with its HTTP status.
+type Error struct {
+	Status  int               `json:"-"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"` // per-field problems, for 400s
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Invalid is a 400 listing what is wrong with each request field.
+func Invalid(fields map[string]string) *Error {
+	return &Error{
+		Status:  fiber.StatusBadRequest,
+		Code:    "validation_failed",
+		Message: "request has invalid fields",
+		Fields:  fields,
+	}
+}
+
+func BadRequest(message string) *Error { return New(fiber.StatusBadRequest, "bad_request", message) }
+func Forbidden(message string) *Error  { return New(fiber.StatusForbidden, "forbidden", message) }
+func NotFound(message string) *Error   { return New(fiber.StatusNotFound, "not_found", message) }
+func Conflict(message string) *Error   { return New(fiber.StatusConflict, "conflict", message) }
+
+// Internal hides err from the client; it is still passed to the request log.
+func Internal(err error) error {
+	return errors.Join(New(fiber.StatusInternalServerError, "internal", "internal server error"), err)
+}
+
+// Handler is the app's fiber.ErrorHandler. *Error is rendered as is;
+// *fiber.Error keeps its status and message; anything else is a 500.
+func Handler(c *fiber.Ctx, err error) error {
+	var e *Error
+	var fe *fiber.Error
+	switch {
+	case errors.As(err, &e):
+	case errors.As(err, &fe):
+		e = New(fe.Code, codeFor(fe.Code), fe.Message)
+	default:
+		e = New(fiber.StatusInternalServerError, "internal", "internal server error")
+	}
+	return c.Status(e.Status).JSON(fiber.Map{"error": e})
+}
+
+// codeFor derives a code from the status text: 404 -> "not_found".
+func codeFor(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
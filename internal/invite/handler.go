@@ -0,0 +1,94 @@
+package invite
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+type Handler struct{ svc *Service }
+
+func NewHandler(s *Service) *Handler { return &Handler{s} }
+
+/* helpers */
+func oid(c *fiber.Ctx) uint { id, _ := strconv.Atoi(c.Params("oid")); return uint(id) }
+func uid(c *fiber.Ctx) uint { return c.Locals("user_id").(uint) }
+
+// apiError maps service errors onto the API error envelope.
+func apiError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, org.ErrNotFound):
+		return httperr.NotFound(err.Error())
+	case errors.Is(err, ErrInvalid):
+		return httperr.BadRequest(err.Error())
+	case errors.Is(err, ErrWrongUser), errors.Is(err, org.ErrNotAllowed):
+		return httperr.Forbidden(err.Error())
+	case errors.Is(err, ErrDisabled):
+		return httperr.New(fiber.StatusServiceUnavailable, "service_unavailable", err.Error())
+	case errors.Is(err, ErrAlreadyMember):
+		return httperr.Conflict(err.Error())
+	case errors.Is(err, ErrInvalidEmail):
+		return httperr.Invalid(map[string]string{"email": err.Error()})
+	case errors.Is(err, org.ErrInvalidRole):
+		return httperr.Invalid(map[string]string{"role": err.Error()})
+	}
+	return httperr.Internal(err)
+}
+
+// GET /orgs/:oid/invitations
+func (h *Handler) List(c *fiber.Ctx) error {
+	is, err := h.svc.List(uid(c), oid(c))
+	if err != nil {
+		return apiError(err)
+	}
+	return c.JSON(is)
+}
+
+// POST /orgs/:oid/invitations  { "email": "dev@acme.io", "role": "editor" }
+// The invitee needn't have signed in before.
+func (h *Handler) Create(c *fiber.Ctx) error {
+	var req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return httperr.BadRequest("body is not valid JSON")
+	}
+	i, err := h.svc.Create(uid(c), oid(c), req.Email, req.Role)
+	if err != nil {
+		return apiError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(i)
+}
+
+// DELETE /orgs/:oid/invitations/:iid
+func (h *Handler) Revoke(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("iid")
+	if err := h.svc.Revoke(uid(c), oid(c), uint(id)); err != nil {
+		return apiError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// POST /invitations/accept  { "token": "..." }
+// For users who are signed in already; others follow the link.
+func (h *Handler) Accept(c *fiber.Ctx) error {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return httperr.BadRequest("body is not valid JSON")
+	}
+	if req.Token == "" {
+		return httperr.Invalid(map[string]string{"token": "is required"})
+	}
+	m, err := h.svc.Accept(uid(c), req.Token)
+	if err != nil {
+		return apiError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(m)
+}
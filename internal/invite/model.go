@@ -0,0 +1,29 @@
+package invite
+
+import (
+	"time"
+
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+// Invitation offers a role in an organization to whoever signs in with
+// Email. It is pending until accepted or it expires; revoking deletes it.
+type Invitation struct {
+	ID         uint       `json:"id"          gorm:"primaryKey"`
+	OrgID      uint       `json:"org_id"      gorm:"index"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	InvitedBy  uint       `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedBy *uint      `json:"accepted_by"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	// Link carries the signed token; it is only set when the invitation
+	// is created.
+	Link string `json:"link,omitempty" gorm:"-"`
+
+	Org org.Organization `json:"-" gorm:"foreignKey:OrgID;constraint:OnDelete:CASCADE"`
+}
+
+func (Invitation) TableName() string { return "invitations" }
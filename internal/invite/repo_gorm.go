@@ -0,0 +1,75 @@
+package invite
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+type gormRepo struct{ db *gorm.DB }
+
+func NewGormRepo(db *gorm.DB) Repository { return &gormRepo{db} }
+
+func (r *gormRepo) Replace(i *Invitation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("org_id = ? AND LOWER(email) = LOWER(?) AND accepted_at IS NULL", i.OrgID, i.Email).
+			Delete(&Invitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Org").Create(i).Error
+	})
+}
+
+func (r *gormRepo) Find(id uint) (*Invitation, error) {
+	var i Invitation
+	err := r.db.Where("id = ? AND accepted_at IS NULL AND expires_at > NOW()", id).First(&i).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalid
+	}
+	return &i, err
+}
+
+func (r *gormRepo) ListPending(oid uint) ([]Invitation, error) {
+	var is []Invitation
+	return is, r.db.Where("org_id = ? AND accepted_at IS NULL AND expires_at > NOW()", oid).
+		Order("created_at DESC").Find(&is).Error
+}
+
+// Delete reports false when no pending invitation matched (id, oid).
+func (r *gormRepo) Delete(id, oid uint) (bool, error) {
+	res := r.db.Where("id = ? AND org_id = ? AND accepted_at IS NULL", id, oid).Delete(&Invitation{})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *gormRepo) Accept(i *Invitation, uid uint, at time.Time) (*org.Member, error) {
+	m := &org.Member{OrgID: i.OrgID, UserID: uid, Role: i.Role}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// only one of two concurrent accepts gets the row
+		res := tx.Model(&Invitation{}).Where("id = ? AND accepted_at IS NULL AND expires_at > NOW()", i.ID).
+			Updates(map[string]interface{}{"accepted_by": uid, "accepted_at": at})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalid
+		}
+		res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(m)
+		if res.Error == nil && res.RowsAffected == 0 {
+			return ErrAlreadyMember
+		}
+		return res.Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *gormRepo) UserEmail(uid uint) (string, error) {
+	var email string
+	return email, r.db.Raw(`SELECT email FROM users WHERE id = ?`, uid).Scan(&email).Error
+}
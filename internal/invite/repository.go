@@ -0,0 +1,22 @@
+package invite
+
+import (
+	"time"
+
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+type Repository interface {
+	// Replace stores i in place of oid's pending invitations for the same
+	// email, in one transaction.
+	Replace(i *Invitation) error
+	// Find returns pending invitation id, or ErrInvalid.
+	Find(id uint) (*Invitation, error)
+	ListPending(oid uint) ([]Invitation, error)
+	Delete(id, oid uint) (bool, error)
+	// Accept marks i accepted by uid and adds uid to its organization in one
+	// transaction. It returns ErrInvalid when i is no longer pending and
+	// ErrAlreadyMember when uid already belongs to the organization.
+	Accept(i *Invitation, uid uint, at time.Time) (*org.Member, error)
+	UserEmail(uid uint) (string, error)
+}
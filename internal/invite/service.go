@@ -0,0 +1,158 @@
+package invite
+
+import (
+	"errors"
+	netmail "net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliRizaAynaci/rlaas/internal/mail"
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+var (
+	ErrNotFound      = errors.New("invitation not found")
+	ErrInvalid       = errors.New("invalid, expired or already used invitation")
+	ErrWrongUser     = errors.New("invitation was sent to a different email address")
+	ErrInvalidEmail  = errors.New("must be a valid email address")
+	ErrDisabled      = errors.New("invitations are disabled: INVITE_SECRET is not set")
+	ErrAlreadyMember = org.ErrAlreadyMember
+)
+
+type Service struct {
+	repo   Repository
+	orgs   *org.Service
+	mailer mail.Mailer
+	secret []byte // signs invite tokens; never the session key
+}
+
+// NewService reads INVITE_SECRET. Without it invitations are disabled
+// rather than signed with a guessable or shared key.
+func NewService(r Repository, orgs *org.Service, m mail.Mailer) *Service {
+	return &Service{repo: r, orgs: orgs, mailer: m, secret: []byte(os.Getenv("INVITE_SECRET"))}
+}
+
+// Enabled reports whether INVITE_SECRET is set.
+func (s *Service) Enabled() bool { return len(s.secret) > 0 }
+
+// Create invites email to oid with role and mails them a link to accept.
+// A pending invitation for the same address is replaced. The returned
+// invitation carries the link, which is not stored.
+func (s *Service) Create(uid, oid uint, email, role string) (*Invitation, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+	if a, err := netmail.ParseAddress(email); err != nil || a.Address != email {
+		return nil, ErrInvalidEmail
+	}
+	if err := s.orgs.CanGrant(uid, oid, role); err != nil {
+		return nil, err
+	}
+	o, err := s.orgs.Get(oid)
+	if err != nil {
+		return nil, err
+	}
+	member, err := s.orgs.IsMember(oid, email)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, ErrAlreadyMember
+	}
+
+	i := &Invitation{OrgID: oid, Email: email, Role: role, InvitedBy: uid, ExpiresAt: time.Now().Add(ttl())}
+	if err := s.repo.Replace(i); err != nil {
+		return nil, err
+	}
+	tok, err := sign(s.secret, i.ID, i.ExpiresAt)
+	if err != nil {
+		_, _ = s.repo.Delete(i.ID, oid)
+		return nil, err
+	}
+	i.Link = link(tok)
+
+	body := "You have been invited to join " + o.Name + " on RLaaS as " + role + ".\n\n" +
+		"Sign in with Google to accept:\n" + i.Link + "\n\n" +
+		"The link expires on " + i.ExpiresAt.UTC().Format("2 Jan 2006 15:04 MST") + "."
+	if err := s.mailer.Send(email, "Invitation to "+o.Name, body); err != nil {
+		_, _ = s.repo.Delete(i.ID, oid)
+		return nil, err
+	}
+	return i, nil
+}
+
+// List returns oid's pending invitations; admins only.
+func (s *Service) List(uid, oid uint) ([]Invitation, error) {
+	if err := s.orgs.RequireRole(uid, oid, org.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.repo.ListPending(oid)
+}
+
+// Revoke deletes a pending invitation, so its link stops working.
+func (s *Service) Revoke(uid, oid, id uint) error {
+	if err := s.orgs.RequireRole(uid, oid, org.RoleAdmin); err != nil {
+		return err
+	}
+	ok, err := s.repo.Delete(id, oid)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Accept makes uid a member as the invitation in tok says. The user's
+// email must be the one the invitation was sent to, and whoever sent it
+// must still be allowed to grant its role.
+func (s *Service) Accept(uid uint, tok string) (*org.Member, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+	id, err := parse(s.secret, tok)
+	if err != nil {
+		return nil, err
+	}
+	i, err := s.repo.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	email, err := s.repo.UserEmail(uid)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(email, i.Email) {
+		return nil, ErrWrongUser
+	}
+	// the inviter may have been demoted or removed since
+	switch err := s.orgs.CanGrant(i.InvitedBy, i.OrgID, i.Role); {
+	case errors.Is(err, org.ErrNotAllowed), errors.Is(err, org.ErrNotFound), errors.Is(err, org.ErrInvalidRole):
+		return nil, ErrInvalid
+	case err != nil:
+		return nil, err
+	}
+	return s.repo.Accept(i, uid, time.Now())
+}
+
+// ttl is INVITE_TTL_HOURS, or a week.
+func ttl() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("INVITE_TTL_HOURS")); err == nil && n > 0 {
+		return time.Duration(n) * time.Hour
+	}
+	return 7 * 24 * time.Hour
+}
+
+// link points at the Google login, which accepts the invitation once the
+// invitee has signed in. PUBLIC_URL is where this API is reachable.
+func link(tok string) string {
+	base := os.Getenv("PUBLIC_URL")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return strings.TrimSuffix(base, "/") + "/auth/google/login?invite=" + url.QueryEscape(tok)
+}
@@ -0,0 +1,291 @@
+package invite
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+var testSecret = []byte("test-secret")
+
+func TestTokenRoundTrip(t *testing.T) {
+	tok, err := sign(testSecret, 42, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := parse(testSecret, tok); err != nil || id != 42 {
+		t.Fatalf("parse = %d, %v; want 42", id, err)
+	}
+}
+
+func TestTokenRejected(t *testing.T) {
+	good, _ := sign(testSecret, 42, time.Now().Add(time.Hour))
+	expired, _ := sign(testSecret, 42, time.Now().Add(-time.Minute))
+	other, _ := sign([]byte("other-secret"), 42, time.Now().Add(time.Hour))
+	tests := map[string]string{
+		"expired":        expired,
+		"other secret":   other,
+		"tampered":       good[:len(good)-2] + "xx",
+		"not a token":    "invite-me",
+		"session secret": mustSession(t),
+	}
+	for name, tok := range tests {
+		if _, err := parse(testSecret, tok); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: err = %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+// mustSession is a token without the inv claim, as a session would be.
+func mustSession(t *testing.T) string {
+	tok, err := sign(testSecret, 0, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+/* ---------- fakes ---------- */
+
+// orgRepo is one organization, 10, with fixed members.
+type orgRepo struct {
+	org.Repository // unused methods panic
+	roles          map[uint]string
+	emails         map[string]uint
+}
+
+func (r *orgRepo) Role(oid, uid uint) (string, error) {
+	if oid != 10 {
+		return "", org.ErrNotFound
+	}
+	return r.roles[uid], nil
+}
+
+func (r *orgRepo) Find(oid uint) (*org.Organization, error) {
+	if oid != 10 {
+		return nil, org.ErrNotFound
+	}
+	return &org.Organization{ID: 10, Name: "Acme"}, nil
+}
+
+func (r *orgRepo) UserIDByEmail(email string) (uint, error) {
+	if uid, ok := r.emails[email]; ok {
+		return uid, nil
+	}
+	return 0, org.ErrUserNotFound
+}
+
+type inviteRepo struct {
+	pending  map[uint]*Invitation
+	nextID   uint
+	accepted []uint
+	emails   map[uint]string
+}
+
+func (r *inviteRepo) Replace(i *Invitation) error {
+	for id, p := range r.pending {
+		if p.OrgID == i.OrgID && p.Email == i.Email {
+			delete(r.pending, id)
+		}
+	}
+	r.nextID++
+	i.ID = r.nextID
+	r.pending[i.ID] = i
+	return nil
+}
+
+func (r *inviteRepo) Find(id uint) (*Invitation, error) {
+	if i, ok := r.pending[id]; ok {
+		return i, nil
+	}
+	return nil, ErrInvalid
+}
+
+func (r *inviteRepo) ListPending(oid uint) ([]Invitation, error) { return nil, nil }
+
+func (r *inviteRepo) Delete(id, oid uint) (bool, error) {
+	_, ok := r.pending[id]
+	delete(r.pending, id)
+	return ok, nil
+}
+
+func (r *inviteRepo) Accept(i *Invitation, uid uint, at time.Time) (*org.Member, error) {
+	delete(r.pending, i.ID)
+	r.accepted = append(r.accepted, uid)
+	return &org.Member{OrgID: i.OrgID, UserID: uid, Role: i.Role}, nil
+}
+
+func (r *inviteRepo) UserEmail(uid uint) (string, error) { return r.emails[uid], nil }
+
+type mailer struct {
+	sent int
+	err  error
+}
+
+func (m *mailer) Send(to, subject, body string) error {
+	m.sent++
+	return m.err
+}
+
+const (
+	ownerID   uint = 1
+	adminID   uint = 2
+	viewerID  uint = 3
+	inviteeID uint = 50
+)
+
+func newTestService() (*Service, *orgRepo, *inviteRepo, *mailer) {
+	or := &orgRepo{
+		roles:  map[uint]string{ownerID: org.RoleOwner, adminID: org.RoleAdmin, viewerID: org.RoleViewer},
+		emails: map[string]uint{"viewer@acme.io": viewerID},
+	}
+	ir := &inviteRepo{pending: make(map[uint]*Invitation), emails: map[uint]string{inviteeID: "New@Acme.io"}}
+	m := &mailer{}
+	s := &Service{repo: ir, orgs: org.NewService(or), mailer: m, secret: testSecret}
+	return s, or, ir, m
+}
+
+/* ---------- service ---------- */
+
+func TestCreateNeedsSecret(t *testing.T) {
+	s, _, ir, m := newTestService()
+	s.secret = nil
+	if _, err := s.Create(adminID, 10, "new@acme.io", org.RoleViewer); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("create without a secret: %v, want ErrDisabled", err)
+	}
+	if _, err := s.Accept(inviteeID, "anything"); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("accept without a secret: %v, want ErrDisabled", err)
+	}
+	if len(ir.pending) != 0 || m.sent != 0 {
+		t.Fatal("a disabled service stored or sent an invitation")
+	}
+}
+
+func TestCreateReplacesPending(t *testing.T) {
+	s, _, ir, m := newTestService()
+	first, err := s.Create(adminID, 10, "new@acme.io", org.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Create(adminID, 10, "new@acme.io", org.RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ir.pending) != 1 || ir.pending[second.ID] == nil || m.sent != 2 {
+		t.Fatalf("pending %v after re-inviting, want only %d", ir.pending, second.ID)
+	}
+	if _, err := s.Accept(inviteeID, tokenOf(t, first)); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("accepting the replaced invitation: %v, want ErrInvalid", err)
+	}
+}
+
+func TestCreateChecks(t *testing.T) {
+	tests := []struct {
+		name  string
+		uid   uint
+		email string
+		role  string
+		want  error
+	}{
+		{"bad email", adminID, "not an email", org.RoleViewer, ErrInvalidEmail},
+		{"viewer can't invite", viewerID, "new@acme.io", org.RoleViewer, org.ErrNotAllowed},
+		{"admin can't invite owners", adminID, "new@acme.io", org.RoleOwner, org.ErrNotAllowed},
+		{"owner invites owners", ownerID, "new@acme.io", org.RoleOwner, nil},
+		{"already a member", adminID, "viewer@acme.io", org.RoleEditor, ErrAlreadyMember},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, _, _ := newTestService()
+			if _, err := s.Create(tc.uid, 10, tc.email, tc.role); !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+// A failed email leaves no invitation behind.
+func TestCreateMailFailure(t *testing.T) {
+	s, _, ir, m := newTestService()
+	m.err = errors.New("smtp down")
+	if _, err := s.Create(adminID, 10, "new@acme.io", org.RoleViewer); !errors.Is(err, m.err) {
+		t.Fatalf("err = %v, want the mail error", err)
+	}
+	if len(ir.pending) != 0 {
+		t.Fatalf("pending %v after the mail failed", ir.pending)
+	}
+}
+
+func tokenOf(t *testing.T, i *Invitation) string {
+	t.Helper()
+	tok, err := sign(testSecret, i.ID, i.ExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestAccept(t *testing.T) {
+	s, _, ir, _ := newTestService()
+	i, err := s.Create(adminID, 10, "new@acme.io", org.RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.Accept(inviteeID, tokenOf(t, i)) // email matches case-insensitively
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.UserID != inviteeID || m.Role != org.RoleEditor || len(ir.accepted) != 1 {
+		t.Fatalf("member = %+v, accepted %v", m, ir.accepted)
+	}
+}
+
+func TestAcceptWrongEmail(t *testing.T) {
+	s, _, ir, _ := newTestService()
+	i, err := s.Create(adminID, 10, "someone-else@acme.io", org.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Accept(inviteeID, tokenOf(t, i)); !errors.Is(err, ErrWrongUser) {
+		t.Fatalf("err = %v, want ErrWrongUser", err)
+	}
+	if len(ir.accepted) != 0 || ir.pending[i.ID] == nil {
+		t.Fatal("the invitation was used up by the wrong user")
+	}
+}
+
+func TestAcceptExpiredToken(t *testing.T) {
+	s, _, _, _ := newTestService()
+	i, err := s.Create(adminID, 10, "new@acme.io", org.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, _ := sign(testSecret, i.ID, time.Now().Add(-time.Second))
+	if _, err := s.Accept(inviteeID, tok); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("err = %v, want ErrInvalid", err)
+	}
+}
+
+// An invitation is only as good as its sender's current role.
+func TestAcceptRechecksInviter(t *testing.T) {
+	for name, demote := range map[string]func(*orgRepo){
+		"inviter demoted": func(r *orgRepo) { r.roles[adminID] = org.RoleViewer },
+		"inviter removed": func(r *orgRepo) { delete(r.roles, adminID) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, or, ir, _ := newTestService()
+			i, err := s.Create(adminID, 10, "new@acme.io", org.RoleEditor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			demote(or)
+			if _, err := s.Accept(inviteeID, tokenOf(t, i)); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("err = %v, want ErrInvalid", err)
+			}
+			if len(ir.accepted) != 0 {
+				t.Fatal("accepted anyway")
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package invite
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// sign returns a token for invitation id that is valid until exp.
+func sign(secret []byte, id uint, exp time.Time) (string, error) {
+	claims := jwt.MapClaims{"inv": id, "exp": exp.Unix()}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// parse returns the invitation id of a token signed by sign.
+func parse(secret []byte, tok string) (uint, error) {
+	t, err := jwt.Parse(tok, func(*jwt.Token) (interface{}, error) { return secret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !t.Valid {
+		return 0, ErrInvalid
+	}
+	id, ok := t.Claims.(jwt.MapClaims)["inv"].(float64)
+	if !ok || id <= 0 {
+		return 0, ErrInvalid
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,188 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rule consistency modes.
+const (
+	ConsistencyStrict      = "strict"      // every check is a backend round trip
+	ConsistencyApproximate = "approximate" // local counting with batched claims
+)
+
+// Approximate-mode tuning; set from APPROX_BATCH_PERCENT and APPROX_SYNC_MS
+// by InitSharding.
+var (
+	approxBatchPercent = 5
+	approxSync         = time.Second
+)
+
+// approxLimiter trades exactness for throughput. Each instance claims a
+// batch of tokens from the shared per-window counter with one IncrBy and
+// then serves checks from memory until the batch is spent. A claim is
+// clamped so that granted tokens never exceed limit within a window;
+// tokens an instance hasn't used are handed back on the next sync.
+//
+// Overshoot bound: accounting is fixed-window, so at most limit requests
+// are admitted per window as long as instance clocks agree. Across a
+// window boundary a burst can reach 2×limit (as with fixed_window),
+// and a clock skew of δ between instances lets each count up to δ of
+// traffic against a neighbouring window. The strategy configured on the
+// rule is not used in this mode.
+type approxLimiter struct {
+	limit  int
+	window time.Duration
+	batch  int64
+	store  Store
+	ns     string        // apiKey:endpoint, keeps rules' counters apart
+	every  time.Duration // sync period, fixed at creation
+
+	mu    sync.Mutex
+	local map[string]*approxBucket
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type approxBucket struct {
+	win       int64 // window index the tokens belong to
+	tokens    int64 // claimed and not yet used
+	exhausted bool  // backend reported the window is used up
+	used      bool  // touched since the last sync
+
+	claiming chan struct{} // closed when the claim in flight returns
+}
+
+func newApprox(cfg RateLimitConfig, store Store, ns string) *approxLimiter {
+	batch := int64(cfg.Limit * approxBatchPercent / 100)
+	if batch < 1 {
+		batch = 1
+	}
+	a := &approxLimiter{
+		limit:  cfg.Limit,
+		window: cfg.Window,
+		batch:  batch,
+		store:  store,
+		ns:     ns,
+		every:  approxSync,
+		local:  make(map[string]*approxBucket),
+		done:   make(chan struct{}),
+	}
+	go a.syncLoop()
+	return a
+}
+
+// Allow serves key from its local batch. A claim runs without a.mu held,
+// so a slow backend only stalls callers of the key being claimed for; they
+// wait for that claim rather than start their own.
+func (a *approxLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	win := time.Now().UnixNano() / int64(a.window)
+
+	for {
+		a.mu.Lock()
+		b, ok := a.local[key]
+		if !ok || b.win != win {
+			b = &approxBucket{win: win} // previous window's leftovers expire with it
+			a.local[key] = b
+		}
+		b.used = true
+
+		if b.tokens > 0 {
+			b.tokens--
+			a.mu.Unlock()
+			return true, nil
+		}
+		if b.exhausted {
+			a.mu.Unlock()
+			return false, nil
+		}
+		if wait := b.claiming; wait != nil {
+			a.mu.Unlock()
+			select {
+			case <-wait:
+				continue // look again at what the claim brought
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		b.claiming = done
+		a.mu.Unlock()
+
+		granted, err := a.claim(ctx, key, win)
+
+		a.mu.Lock()
+		b.claiming = nil
+		close(done)
+		switch {
+		case err != nil:
+		case granted == 0:
+			b.exhausted = true
+		default:
+			b.tokens += granted - 1 // lost if the window rolled meanwhile
+		}
+		a.mu.Unlock()
+		return err == nil && granted > 0, err
+	}
+}
+
+// claim reserves up to batch tokens from the shared counter for win.
+func (a *approxLimiter) claim(ctx context.Context, key string, win int64) (int64, error) {
+	total, err := a.store.IncrBy(ctx, a.counterKey(key, win), a.batch, a.window)
+	if err != nil {
+		return 0, err
+	}
+	before := int64(total) - a.batch
+	return min(max(int64(a.limit)-before, 0), a.batch), nil
+}
+
+// stop ends syncLoop once the limiter is evicted. Tokens still held are
+// not returned; the window only under-admits by that much.
+func (a *approxLimiter) stop() { a.stopOnce.Do(func() { close(a.done) }) }
+
+// syncLoop periodically returns tokens held by keys that went idle and
+// forgets buckets from past windows.
+func (a *approxLimiter) syncLoop() {
+	t := time.NewTicker(a.every)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-t.C:
+		}
+		win := time.Now().UnixNano() / int64(a.window)
+
+		type refund struct {
+			key    string
+			tokens int64
+		}
+		var refunds []refund
+
+		a.mu.Lock()
+		for k, b := range a.local {
+			switch {
+			case b.win != win:
+				delete(a.local, k)
+			case !b.used && b.tokens > 0:
+				refunds = append(refunds, refund{k, b.tokens})
+				b.tokens = 0
+			}
+			b.used = false
+		}
+		a.mu.Unlock()
+
+		for _, r := range refunds {
+			ctx, cancel := context.WithTimeout(context.Background(), a.every)
+			_, _ = a.store.IncrBy(ctx, a.counterKey(r.key, win), -r.tokens, a.window)
+			cancel()
+		}
+	}
+}
+
+func (a *approxLimiter) counterKey(key string, win int64) string {
+	return "rlaas:ap:" + a.ns + ":" + key + ":" + strconv.FormatInt(win, 10)
+}
@@ -0,0 +1,90 @@
+package limiter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+// gatedStore holds IncrBy on keys containing "slow" until release closes.
+type gatedStore struct {
+	Store
+	release chan struct{}
+	claims  atomic.Int32
+}
+
+func (g *gatedStore) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (float64, error) {
+	if strings.Contains(key, "slow") {
+		g.claims.Add(1)
+		<-g.release
+	}
+	return g.Store.IncrBy(ctx, key, n, ttl)
+}
+
+// A claim stuck on the backend must not hold up other keys, and callers
+// of the stuck key wait for it instead of claiming again.
+func TestApproxSlowClaimBlocksOnlyItsKey(t *testing.T) {
+	store := &gatedStore{Store: newMemoryStore(), release: make(chan struct{})}
+	a := newApprox(ruleCfg(1, core.FixedWindow, 100), store, "proj:/x")
+	defer a.stop()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	slow := make(chan bool, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, _ := a.Allow(ctx, "slow")
+			slow <- ok
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	fast := make(chan bool)
+	go func() {
+		ok, _ := a.Allow(ctx, "fast")
+		fast <- ok
+	}()
+	select {
+	case ok := <-fast:
+		if !ok {
+			t.Fatal("fast key denied")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("fast key waited for the slow key's claim")
+	}
+
+	close(store.release)
+	wg.Wait()
+	close(slow)
+	for ok := range slow {
+		if !ok {
+			t.Fatal("slow key denied once its claim returned")
+		}
+	}
+	if n := store.claims.Load(); n != 1 {
+		t.Fatalf("%d concurrent claims for one key, want 1", n)
+	}
+}
+
+func TestApproxWaiterHonoursContext(t *testing.T) {
+	store := &gatedStore{Store: newMemoryStore(), release: make(chan struct{})}
+	defer close(store.release)
+	a := newApprox(ruleCfg(1, core.FixedWindow, 100), store, "proj:/x")
+	defer a.stop()
+
+	go a.Allow(context.Background(), "slow")
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if ok, err := a.Allow(ctx, "slow"); ok || err == nil {
+		t.Fatalf("Allow = %v, %v; want the context's error", ok, err)
+	}
+}
@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// LIMITER_BACKEND values. Only "redis" is sharded by rlaas itself; the
+// others are a single logical node that distributes (or doesn't) on its own.
+const (
+	BackendRedis    = "redis"    // standalone nodes, sharded by ShardSelector
+	BackendCluster  = "cluster"  // Redis Cluster, hash-slot routing by go-redis
+	BackendSentinel = "sentinel" // Sentinel-managed master with failover
+	BackendPostgres = "postgres" // limiter_counters table, low-volume tenants
+	BackendMemory   = "memory"   // in-process, dev and tests
+)
+
+var (
+	ErrUnknownBackend = errors.New("unknown LIMITER_BACKEND")
+	ErrNoPostgres     = errors.New("postgres limiter backend needs UsePostgres")
+)
+
+var (
+	pgMu sync.Mutex
+	pgDB *gorm.DB
+)
+
+// UsePostgres hands the limiter the DB handle used by the postgres backend
+// and, for the redis backend, to share the shard list between replicas.
+func UsePostgres(db *gorm.DB) {
+	pgMu.Lock()
+	defer pgMu.Unlock()
+	pgDB = db
+}
+
+func postgresDB() *gorm.DB {
+	pgMu.Lock()
+	defer pgMu.Unlock()
+	return pgDB
+}
+
+// backendNode is the single logical node for non-sharded backends.
+func backendNode() Node {
+	switch backend {
+	case BackendCluster:
+		return Node{URL: "cluster://" + os.Getenv("REDIS_CLUSTER_ADDRS")}
+	case BackendSentinel:
+		return Node{URL: "sentinel://" + getEnvOrDefault("REDIS_SENTINEL_MASTER", "mymaster")}
+	case BackendPostgres:
+		return Node{URL: "postgres://limiter_counters"}
+	default:
+		return Node{URL: "memory://local"}
+	}
+}
+
+// openStore dials the Store behind a node URL for the configured backend.
+func openStore(url string) (Store, error) {
+	switch backend {
+	case BackendRedis:
+		opt, err := redis.ParseURL(url)
+		if err != nil {
+			return nil, err
+		}
+		return newRedisStore(redis.NewClient(opt)), nil
+
+	case BackendCluster:
+		return newRedisStore(redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    splitList(os.Getenv("REDIS_CLUSTER_ADDRS")),
+			Username: os.Getenv("REDIS_USERNAME"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+		})), nil
+
+	case BackendSentinel:
+		return newRedisStore(redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       getEnvOrDefault("REDIS_SENTINEL_MASTER", "mymaster"),
+			SentinelAddrs:    splitList(os.Getenv("REDIS_SENTINEL_ADDRS")),
+			SentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+			Username:         os.Getenv("REDIS_USERNAME"),
+			Password:         os.Getenv("REDIS_PASSWORD"),
+			DB:               getEnvInt("REDIS_DB", 0),
+		})), nil
+
+	case BackendPostgres:
+		pgMu.Lock()
+		defer pgMu.Unlock()
+		if pgDB == nil {
+			return nil, ErrNoPostgres
+		}
+		return newPostgresStore(pgDB), nil
+
+	case BackendMemory:
+		return newMemoryStore(), nil
+	}
+	return nil, ErrUnknownBackend
+}
+
+func closeQuietly(s Store) {
+	if c, ok := s.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package limiter
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/gorl/core"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// checkBackend runs a single-rule and a multi-rule limit against the
+// configured backend.
+func checkBackend(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+
+	one := []RateLimitConfig{ruleCfg(1, core.SlidingWindow, 3)}
+	two := []RateLimitConfig{ruleCfg(2, core.FixedWindow, 100), ruleCfg(3, core.TokenBucket, 2)}
+	for _, tt := range []struct {
+		name string
+		cfgs []RateLimitConfig
+		n    int
+	}{{"one rule", one, 3}, {"two rules", two, 2}} {
+		for i := 0; i < tt.n; i++ {
+			if d := AllowAll(ctx, "proj", "/x", "u", tt.cfgs); !d.Allowed || d.Err != nil {
+				t.Fatalf("%s: request %d = %+v", tt.name, i+1, d)
+			}
+		}
+		if d := AllowAll(ctx, "proj", "/x", "u", tt.cfgs); d.Allowed || d.Reason != ReasonLimited {
+			t.Fatalf("%s: request over the limit = %+v", tt.name, d)
+		}
+	}
+}
+
+func TestClusterBackend(t *testing.T) {
+	m := miniredis.RunT(t) // answers CLUSTER SLOTS as a one-node cluster
+	useBackend(t, map[string]string{
+		"LIMITER_BACKEND":     BackendCluster,
+		"REDIS_CLUSTER_ADDRS": m.Addr(),
+	})
+	checkBackend(t)
+	if len(m.Keys()) == 0 {
+		t.Fatal("no counters written to the cluster")
+	}
+}
+
+func TestSentinelBackend(t *testing.T) {
+	master := miniredis.RunT(t)
+	sentinel := miniredis.RunT(t)
+	host, port, _ := strings.Cut(master.Addr(), ":")
+	err := sentinel.Server().Register("SENTINEL", func(c *server.Peer, cmd string, args []string) {
+		switch {
+		case len(args) == 2 && strings.EqualFold(args[0], "get-master-addr-by-name") && args[1] == "limits":
+			c.WriteLen(2)
+			c.WriteBulk(host)
+			c.WriteBulk(port)
+		case len(args) >= 1 && strings.EqualFold(args[0], "sentinels"):
+			c.WriteLen(0)
+		default:
+			c.WriteError("ERR unsupported SENTINEL " + strings.Join(args, " "))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useBackend(t, map[string]string{
+		"LIMITER_BACKEND":       BackendSentinel,
+		"REDIS_SENTINEL_ADDRS":  sentinel.Addr(),
+		"REDIS_SENTINEL_MASTER": "limits",
+	})
+	checkBackend(t)
+	if len(master.Keys()) == 0 {
+		t.Fatal("no counters written to the master the sentinel named")
+	}
+	if len(sentinel.Keys()) != 0 {
+		t.Fatal("counters written to the sentinel itself")
+	}
+}
+
+// TestPostgresBackend needs a scratch database in
+// LIMITER_TEST_POSTGRES_DSN, e.g. the docker-compose Postgres.
+func TestPostgresBackend(t *testing.T) {
+	dsn := os.Getenv("LIMITER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("LIMITER_TEST_POSTGRES_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&Counter{}); err != nil {
+		t.Fatal(err)
+	}
+	prefix := "test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	t.Cleanup(func() { db.Exec(`DELETE FROM limiter_counters WHERE key LIKE ?`, "%"+prefix+"%") })
+
+	UsePostgres(db)
+	t.Cleanup(func() { UsePostgres(nil) })
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendPostgres})
+
+	ctx := context.Background()
+	s := newPostgresStore(db)
+	if v, err := s.IncrBy(ctx, prefix+":k", 2, 50*time.Millisecond); err != nil || v != 2 {
+		t.Fatalf("IncrBy = %v, %v; want 2", v, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if v, err := s.Get(ctx, prefix+":k"); err != nil || v != 0 {
+		t.Fatalf("Get after ttl = %v, %v; want 0", v, err)
+	}
+	if v, err := s.Incr(ctx, prefix+":k", time.Minute); err != nil || v != 1 {
+		t.Fatalf("Incr after ttl = %v, %v; want a fresh count", v, err)
+	}
+
+	cfg := []RateLimitConfig{ruleCfg(1, core.FixedWindow, 2)}
+	for i := 0; i < 2; i++ {
+		if d := AllowAll(ctx, prefix, "/x", "u", cfg); !d.Allowed || d.Err != nil {
+			t.Fatalf("request %d = %+v", i+1, d)
+		}
+	}
+	if d := AllowAll(ctx, prefix, "/x", "u", cfg); d.Allowed {
+		t.Fatal("third request allowed")
+	}
+}
@@ -0,0 +1,209 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling a shard whose breaker is open.
+var ErrCircuitOpen = errors.New("limiter: circuit open")
+
+// Breaker tuning; set from BREAKER_FAILURES, BREAKER_COOLDOWN_MS and
+// BREAKER_MIN_DEADLINE_MS by InitSharding.
+var (
+	breakerFailures    = 5
+	breakerCooldown    = 5 * time.Second
+	breakerMinDeadline = 100 * time.Millisecond
+)
+
+// Breaker states, as reported in ShardStatus.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// breaker trips after breakerFailures consecutive failed calls and then
+// rejects calls outright for breakerCooldown. After the cooldown a single
+// trial call is let through (half-open): success closes the breaker,
+// failure re-opens it for another cooldown. The closed path is lock-free.
+type breaker struct {
+	state    atomic.Pointer[string]
+	failures atomic.Int32
+
+	mu       sync.Mutex
+	openedAt time.Time
+	trial    bool // half-open trial in flight
+}
+
+func newBreaker() *breaker {
+	b := &breaker{}
+	b.set(BreakerClosed)
+	return b
+}
+
+func (b *breaker) set(s string) { b.state.Store(&s) }
+
+// State returns the breaker's current state.
+func (b *breaker) State() string { return *b.state.Load() }
+
+// allow reports whether a call may go through. A true result must be
+// followed by done.
+func (b *breaker) allow() bool {
+	if b.State() == BreakerClosed {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.State() {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if time.Since(b.openedAt) < breakerCooldown {
+			return false
+		}
+		b.set(BreakerHalfOpen)
+		b.trial = true
+		return true
+	default: // half-open: only one trial call at a time
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+}
+
+// done records the outcome of a call that allow let through, given the
+// time the caller allowed for it. The caller giving up says nothing about
+// the shard: a cancel, or a deadline shorter than breakerMinDeadline, which
+// one tenant's tight timeout_ms would otherwise turn into an open circuit
+// for every tenant on the shard. Such a call counts as neither success nor
+// failure, and as a trial it makes room for the next one.
+func (b *breaker) done(err error, budget time.Duration) {
+	if errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) && budget < breakerMinDeadline {
+		if b.State() != BreakerClosed {
+			b.mu.Lock()
+			if b.State() == BreakerHalfOpen {
+				b.trial = false
+			}
+			b.mu.Unlock()
+		}
+		return
+	}
+
+	if b.State() == BreakerClosed {
+		if err == nil {
+			if b.failures.Load() != 0 {
+				b.failures.Store(0)
+			}
+			return
+		}
+		if b.failures.Add(1) < int32(breakerFailures) {
+			return
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.State() == BreakerHalfOpen && b.trial:
+		b.trial = false
+		if err == nil {
+			b.failures.Store(0)
+			b.set(BreakerClosed)
+			return
+		}
+		b.openedAt = time.Now()
+		b.set(BreakerOpen)
+	case b.State() == BreakerClosed && err != nil && b.failures.Load() >= int32(breakerFailures):
+		b.openedAt = time.Now()
+		b.set(BreakerOpen)
+	}
+}
+
+/* ---------- breaker-guarded store ---------- */
+
+// breakerStore guards every data call to a shard with its breaker, so a
+// flapping shard costs one fast ErrCircuitOpen rather than a dial timeout.
+// Ping bypasses the breaker: health probes must keep reaching the shard.
+type breakerStore struct {
+	Store
+	br *breaker
+}
+
+func newBreakerStore(s Store) *breakerStore {
+	return &breakerStore{Store: s, br: newBreaker()}
+}
+
+func guard[T any](ctx context.Context, b *breaker, call func() (T, error)) (T, error) {
+	if !b.allow() {
+		var zero T
+		return zero, ErrCircuitOpen
+	}
+	budget := time.Duration(math.MaxInt64)
+	if dl, ok := ctx.Deadline(); ok {
+		budget = time.Until(dl)
+	}
+	v, err := call()
+	b.done(err, budget)
+	return v, err
+}
+
+func (s *breakerStore) Incr(ctx context.Context, key string, ttl time.Duration) (float64, error) {
+	return guard(ctx, s.br, func() (float64, error) { return s.Store.Incr(ctx, key, ttl) })
+}
+
+func (s *breakerStore) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (float64, error) {
+	return guard(ctx, s.br, func() (float64, error) { return s.Store.IncrBy(ctx, key, n, ttl) })
+}
+
+func (s *breakerStore) Get(ctx context.Context, key string) (float64, error) {
+	return guard(ctx, s.br, func() (float64, error) { return s.Store.Get(ctx, key) })
+}
+
+func (s *breakerStore) Set(ctx context.Context, key string, val float64, ttl time.Duration) error {
+	_, err := guard(ctx, s.br, func() (struct{}, error) { return struct{}{}, s.Store.Set(ctx, key, val, ttl) })
+	return err
+}
+
+func (s *breakerStore) consume(ctx context.Context, cs []counter, nowMs int64) (int, error) {
+	return guard(ctx, s.br, func() (int, error) { return s.multi().consume(ctx, cs, nowMs) })
+}
+
+func (s *breakerStore) refund(ctx context.Context, cs []counter, nowMs int64) error {
+	_, err := guard(ctx, s.br, func() (struct{}, error) { return struct{}{}, s.multi().refund(ctx, cs, nowMs) })
+	return err
+}
+
+func (s *breakerStore) peek(ctx context.Context, cs []counter, nowMs int64) ([]float64, error) {
+	return guard(ctx, s.br, func() ([]float64, error) { return s.multi().peek(ctx, cs, nowMs) })
+}
+
+func (s *breakerStore) multi() multiStore {
+	if ms, ok := s.Store.(multiStore); ok {
+		return ms
+	}
+	return &genericMulti{store: s.Store}
+}
+
+func (s *breakerStore) Close() error {
+	closeQuietly(s.Store)
+	return nil
+}
+
+// breakerState returns the breaker state of url's pool, or closed if the
+// pool hasn't been opened yet.
+func breakerState(url string) string {
+	s, _ := stores.Load(url)
+	if bs, ok := s.(*breakerStore); ok {
+		return bs.br.State()
+	}
+	return BreakerClosed
+}
@@ -0,0 +1,160 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+func withBreakerTuning(t *testing.T, failures int, cooldown time.Duration) {
+	f, c := breakerFailures, breakerCooldown
+	breakerFailures, breakerCooldown = failures, cooldown
+	t.Cleanup(func() { breakerFailures, breakerCooldown = f, c })
+}
+
+// call runs one guarded call that returns err.
+func call(b *breaker, err error) bool {
+	if !b.allow() {
+		return false
+	}
+	b.done(err, time.Minute)
+	return true
+}
+
+var errBackend = errors.New("backend down")
+
+func TestBreakerTripsAndRecovers(t *testing.T) {
+	withBreakerTuning(t, 2, 20*time.Millisecond)
+	b := newBreaker()
+
+	call(b, errBackend)
+	call(b, errBackend)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state after 2 failures = %s, want open", b.State())
+	}
+	if call(b, nil) {
+		t.Fatal("open breaker let a call through")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if !call(b, errBackend) {
+		t.Fatal("no trial after the cooldown")
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("state after a failed trial = %s, want open", b.State())
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if !call(b, nil) || b.State() != BreakerClosed {
+		t.Fatalf("state after a good trial = %s, want closed", b.State())
+	}
+}
+
+// A trial the client cancelled says nothing about the backend.
+func TestBreakerCancelledTrialStaysHalfOpen(t *testing.T) {
+	withBreakerTuning(t, 1, 20*time.Millisecond)
+	b := newBreaker()
+	call(b, errBackend)
+	time.Sleep(25 * time.Millisecond)
+
+	if !b.allow() {
+		t.Fatal("no trial after the cooldown")
+	}
+	if b.allow() {
+		t.Fatal("second call let through during the trial")
+	}
+	b.done(context.Canceled, time.Minute)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state after a cancelled trial = %s, want half_open", b.State())
+	}
+
+	if !b.allow() {
+		t.Fatal("no new trial after the cancelled one")
+	}
+	b.done(errBackend, time.Minute)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state after the failed retrial = %s, want open", b.State())
+	}
+}
+
+// Cancelled calls neither reset nor extend a run of failures.
+func TestBreakerIgnoresCancelledCalls(t *testing.T) {
+	withBreakerTuning(t, 2, time.Minute)
+	b := newBreaker()
+
+	call(b, errBackend)
+	call(b, context.Canceled)
+	call(b, errBackend)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s, want open: the cancel reset the failure count", b.State())
+	}
+
+	b = newBreaker()
+	call(b, errBackend)
+	call(b, context.Canceled)
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s, want closed: the cancel counted as a failure", b.State())
+	}
+}
+
+// A deadline too short for any shard is the caller's problem; a generous
+// one that still expires is the shard's.
+func TestBreakerIgnoresShortCallerDeadlines(t *testing.T) {
+	withBreakerTuning(t, 2, time.Minute)
+	b := newBreaker()
+	for i := 0; i < 10; i++ {
+		b.allow()
+		b.done(context.DeadlineExceeded, breakerMinDeadline/10)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s, want closed after short deadlines", b.State())
+	}
+
+	for i := 0; i < 2; i++ {
+		b.allow()
+		b.done(context.DeadlineExceeded, breakerMinDeadline)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s, want open after full deadlines expired", b.State())
+	}
+}
+
+// hangingStore never answers; calls return when the caller gives up.
+type hangingStore struct{ Store }
+
+func (h *hangingStore) Get(ctx context.Context, key string) (float64, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+// useStore serves the memory backend's only node from s.
+func useStore(t *testing.T, s Store) *breakerStore {
+	t.Helper()
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	bs := newBreakerStore(s)
+	stores.Store(Nodes()[0].URL, bs)
+	return bs
+}
+
+// One tenant's tiny timeout_ms must not open the shard's breaker for the
+// others.
+func TestShortRuleTimeoutKeepsBreakerClosed(t *testing.T) {
+	withBreakerTuning(t, 2, time.Minute)
+	bs := useStore(t, &hangingStore{Store: newMemoryStore()})
+	cfg := ruleCfg(1, core.FixedWindow, 10)
+	cfg.Timeout = 2 * time.Millisecond
+	cfg.FailMode = FailModeOpen
+
+	for i := 0; i < 10; i++ {
+		d := AllowAll(context.Background(), "proj", "/x", "u", []RateLimitConfig{cfg})
+		if !IsTimeout(d.Err) {
+			t.Fatalf("call %d: err = %v, want a timeout", i, d.Err)
+		}
+	}
+	if st := bs.br.State(); st != BreakerClosed {
+		t.Fatalf("breaker %s after short timeouts, want closed", st)
+	}
+}
@@ -0,0 +1,37 @@
+package limiter
+
+// Decision reasons, reported by /check and in request logs.
+const (
+	ReasonAllowed    = "allowed"                  // within limits
+	ReasonLimited    = "limited"                  // a limit was exceeded
+	ReasonFailOpen   = "fail_open"                // backend down, rule fails open
+	ReasonFailClosed = "fail_closed_backend_down" // backend down, rule fails closed
+	ReasonFailLocal  = "fail_local"               // backend down, decided by the local fallback
+)
+
+// Decision is the outcome of one limiter evaluation.
+type Decision struct {
+	Allowed bool
+	Reason  string
+	Err     error // backend error behind a fail_* reason
+	Rule    int   // AllowAll only: index of the denying rule, else -1
+}
+
+// TimedOut reports whether the decision was forced by an expired deadline.
+func (d Decision) TimedOut() bool { return IsTimeout(d.Err) }
+
+// decide maps a raw algorithm result and the rule's fail mode to a
+// Decision; local is consulted for fail_local rules.
+func decide(allowed bool, err error, mode string, local func() bool) Decision {
+	switch {
+	case err == nil && allowed:
+		return Decision{Allowed: true, Reason: ReasonAllowed, Rule: -1}
+	case err == nil:
+		return Decision{Reason: ReasonLimited, Rule: -1}
+	case mode == FailModeOpen:
+		return Decision{Allowed: true, Reason: ReasonFailOpen, Err: err, Rule: -1}
+	case mode == FailModeLocal && local != nil:
+		return Decision{Allowed: local(), Reason: ReasonFailLocal, Err: err, Rule: -1}
+	}
+	return Decision{Reason: ReasonFailClosed, Err: err, Rule: -1}
+}
@@ -0,0 +1,80 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Fail modes decide what happens when the backend can't answer.
+const (
+	FailModeOpen   = "open"   // allow everything
+	FailModeClosed = "closed" // deny everything
+	FailModeLocal  = "local"  // enforce limit/instances in-process
+)
+
+// instances is the number of rlaas replicas sharing the limits; a
+// fail_local rule enforces Limit/instances per replica. Set from
+// RLAAS_INSTANCES by InitSharding.
+var instances = 1
+
+var (
+	localOnce  sync.Once
+	localStore *memoryStore
+)
+
+// fallbackStore is the process-wide store used while a backend is down.
+func fallbackStore() *memoryStore {
+	localOnce.Do(func() { localStore = newMemoryStore() })
+	return localStore
+}
+
+// failMode resolves the rule's mode; rules predating FailMode fall back to
+// the FailOpen flag.
+func (c RateLimitConfig) failMode() string {
+	switch c.FailMode {
+	case FailModeOpen, FailModeClosed, FailModeLocal:
+		return c.FailMode
+	}
+	if c.FailOpen {
+		return FailModeOpen
+	}
+	return FailModeClosed
+}
+
+// localShare is this replica's slice of limit, never below 1.
+func localShare(limit int) int {
+	return max(1, (limit+instances-1)/instances)
+}
+
+// newLocalFallback builds the in-process limiter an approximate fail_local
+// rule falls back to. It counts strictly, on the same per-replica counter
+// AllowAll uses for the rule while its shard is down.
+func newLocalFallback(apiKey string, cfg RateLimitConfig) (algorithm, error) {
+	if _, err := counterKind(cfg.Strategy); err != nil {
+		return nil, err
+	}
+	return &localFallback{apiKey: apiKey, cfg: cfg}, nil
+}
+
+type localFallback struct {
+	apiKey string
+	cfg    RateLimitConfig
+}
+
+func (f *localFallback) Allow(ctx context.Context, key string) (bool, error) {
+	c, err := strictCounter(RequestTag(f.apiKey, key), f.cfg)
+	if err != nil {
+		return false, err
+	}
+	fb := &genericMulti{store: fallbackStore()}
+	denied, err := fb.consume(ctx, []counter{localCounter(c)}, time.Now().UnixMilli())
+	return err == nil && denied < 0, err
+}
+
+// localCounter is c's per-replica stand-in while its backend is down.
+func localCounter(c counter) counter {
+	c.key = "local:" + c.key
+	c.limit = localShare(c.limit)
+	return c
+}
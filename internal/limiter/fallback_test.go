@@ -0,0 +1,61 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+func TestLocalShare(t *testing.T) {
+	cases := []struct{ limit, instances, want int }{
+		{10, 1, 10},
+		{10, 4, 3}, // rounded up: four replicas may allow 12, not 8
+		{12, 4, 3},
+		{1, 5, 1}, // never below 1
+	}
+	for _, c := range cases {
+		instances = c.instances
+		if got := localShare(c.limit); got != c.want {
+			t.Errorf("localShare(%d) with %d instances = %d, want %d", c.limit, c.instances, got, c.want)
+		}
+	}
+	instances = 1
+}
+
+// While its shard is down a fail_local rule allows limit/RLAAS_INSTANCES per
+// replica; once the shard answers again the shared count takes over.
+func TestFailLocalEnforcesReplicaShareUntilRecovery(t *testing.T) {
+	shards, nodes := startShards(t, 1)
+	useBackend(t, map[string]string{
+		"LIMITER_BACKEND": BackendRedis,
+		"REDIS_NODES":     nodes,
+		"RLAAS_INSTANCES": "4",
+	})
+	withBreakerTuning(t, 1000, time.Minute) // keep the breaker out of it
+	cfg := ruleCfg(1, core.FixedWindow, 10)
+	cfg.FailMode = FailModeLocal
+	ctx := context.Background()
+	check := func() Decision { return AllowAll(ctx, "proj", "/x", "u", []RateLimitConfig{cfg}) }
+
+	shards[0].SetError("shard down")
+	for i := 0; i < 3; i++ {
+		if d := check(); !d.Allowed || d.Reason != ReasonFailLocal {
+			t.Fatalf("request %d while down = %+v, want allowed by fail_local", i+1, d)
+		}
+	}
+	if d := check(); d.Allowed || d.Reason != ReasonFailLocal {
+		t.Fatalf("request 4 while down = %+v, want denied: the replica share is 3", d)
+	}
+
+	shards[0].SetError("")
+	for i := 0; i < 10; i++ {
+		if d := check(); !d.Allowed || d.Reason != ReasonAllowed {
+			t.Fatalf("request %d after recovery = %+v, want allowed by the shard", i+1, d)
+		}
+	}
+	if d := check(); d.Allowed || d.Reason != ReasonLimited {
+		t.Fatalf("request 11 after recovery = %+v, want limited by the shared count", d)
+	}
+}
@@ -0,0 +1,140 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Probe tuning; set from SHARD_PROBE_INTERVAL_MS and SHARD_UNHEALTHY_AFTER.
+var (
+	probeInterval  = 2 * time.Second
+	unhealthyAfter = 3
+)
+
+// ShardStatus is the externally visible health of one shard.
+type ShardStatus struct {
+	URL       string    `json:"url"`
+	Healthy   bool      `json:"healthy"`
+	Failures  int       `json:"consecutive_failures"`
+	LastError string    `json:"last_error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	Breaker   string    `json:"breaker"` // closed | open | half_open
+}
+
+var (
+	health   = make(map[string]*ShardStatus)
+	healthMu sync.RWMutex
+
+	// down is an immutable snapshot of unhealthy URLs, republished by
+	// record, so the per-check lookup takes no lock.
+	down atomic.Pointer[map[string]bool]
+)
+
+// isHealthy reports whether url may receive traffic. Shards that have not
+// been probed yet are assumed healthy.
+func isHealthy(url string) bool {
+	if m := down.Load(); m != nil {
+		return !(*m)[url]
+	}
+	return true
+}
+
+// publishDown rebuilds the down snapshot; callers hold healthMu.
+func publishDown() {
+	m := make(map[string]bool)
+	for url, st := range health {
+		if !st.Healthy {
+			m[url] = true
+		}
+	}
+	down.Store(&m)
+}
+
+// ShardHealth returns the status of every current shard.
+func ShardHealth() []ShardStatus {
+	healthMu.RLock()
+	defer healthMu.RUnlock()
+
+	var out []ShardStatus
+	for _, n := range Nodes() {
+		st, ok := health[n.URL]
+		if !ok {
+			st = &ShardStatus{URL: n.URL, Healthy: true}
+		}
+		s := *st
+		s.Breaker = breakerState(n.URL)
+		out = append(out, s)
+	}
+	return out
+}
+
+// StartHealthChecks probes every shard in the background until ctx is done.
+// A shard is marked unhealthy after unhealthyAfter consecutive failed PINGs
+// and healthy again after the first successful one.
+func StartHealthChecks(ctx context.Context) {
+	probeInterval = time.Duration(getEnvInt("SHARD_PROBE_INTERVAL_MS", 2000)) * time.Millisecond
+	unhealthyAfter = getEnvInt("SHARD_UNHEALTHY_AFTER", 3)
+
+	go func() {
+		t := time.NewTicker(probeInterval)
+		defer t.Stop()
+		for {
+			probeAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+func probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, n := range Nodes() {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			record(url, probe(ctx, url))
+		}(n.URL)
+	}
+	wg.Wait()
+}
+
+func probe(ctx context.Context, url string) error {
+	s, err := storeFor(url)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeInterval/2)
+	defer cancel()
+	return s.Ping(ctx)
+}
+
+func record(url string, err error) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	st, ok := health[url]
+	if !ok {
+		st = &ShardStatus{URL: url, Healthy: true}
+		health[url] = st
+	}
+	st.CheckedAt = time.Now()
+
+	was := st.Healthy
+	if err == nil {
+		st.Healthy, st.Failures, st.LastError = true, 0, ""
+	} else {
+		st.Failures++
+		st.LastError = err.Error()
+		if st.Failures >= unhealthyAfter {
+			st.Healthy = false
+		}
+	}
+	if st.Healthy != was {
+		publishDown()
+	}
+}
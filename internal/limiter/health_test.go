@@ -0,0 +1,103 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func withProbeTuning(t *testing.T, after int) {
+	i, a := probeInterval, unhealthyAfter
+	probeInterval, unhealthyAfter = 200*time.Millisecond, after
+	t.Cleanup(func() { probeInterval, unhealthyAfter = i, a })
+}
+
+func TestRecordMarksDownAfterConsecutiveFailures(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	withProbeTuning(t, 3)
+	const url = "redis://shard-a/0"
+
+	record(url, errBackend)
+	record(url, errBackend)
+	if !isHealthy(url) {
+		t.Fatal("down after 2 failures, want 3")
+	}
+	record(url, nil) // a success resets the run
+	record(url, errBackend)
+	record(url, errBackend)
+	if !isHealthy(url) {
+		t.Fatal("failures before a success counted towards the run")
+	}
+	record(url, errBackend)
+	if isHealthy(url) {
+		t.Fatal("still healthy after 3 consecutive failures")
+	}
+	record(url, nil)
+	if !isHealthy(url) {
+		t.Fatal("still down after a successful probe")
+	}
+}
+
+func TestUnprobedShardIsHealthy(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	if !isHealthy("redis://never-probed/0") {
+		t.Fatal("unprobed shard reported down")
+	}
+}
+
+// A key whose shard stops answering probes moves to another shard, and back
+// once the shard answers again.
+func TestSelectorSkipsDownShardAndFailsBack(t *testing.T) {
+	shards, nodes := startShards(t, 3)
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendRedis, "REDIS_NODES": nodes})
+	withProbeTuning(t, 2)
+	ctx := context.Background()
+
+	const key = "proj:u"
+	primary := shardSelector.Load().GetRedisURL(key)
+	var shard int
+	for i, n := range Nodes() {
+		if n.URL == primary {
+			shard = i
+		}
+	}
+
+	shards[shard].Close()
+	probeAll(ctx)
+	if got := shardSelector.Load().GetRedisURL(key); got != primary {
+		t.Fatalf("moved to %s after one failed probe, want to stay on %s", got, primary)
+	}
+	probeAll(ctx)
+	if got := shardSelector.Load().GetRedisURL(key); got == primary {
+		t.Fatalf("still routed to %s after it went down", primary)
+	}
+	for _, st := range ShardHealth() {
+		if st.URL == primary && (st.Healthy || st.LastError == "") {
+			t.Fatalf("status of the down shard = %+v", st)
+		}
+	}
+
+	if err := shards[shard].Restart(); err != nil {
+		t.Fatal(err)
+	}
+	probeAll(ctx)
+	if got := shardSelector.Load().GetRedisURL(key); got != primary {
+		t.Fatalf("routed to %s after recovery, want %s", got, primary)
+	}
+}
+
+// With every shard down there is nowhere better to go; keys stay put.
+func TestSelectorKeepsPrimaryWhenAllDown(t *testing.T) {
+	_, nodes := startShards(t, 2)
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendRedis, "REDIS_NODES": nodes})
+	withProbeTuning(t, 1)
+
+	primary := shardSelector.Load().GetRedisURL("k")
+	for _, n := range Nodes() {
+		record(n.URL, errors.New("down"))
+	}
+	if got := shardSelector.Load().GetRedisURL("k"); got != primary {
+		t.Fatalf("routed to %s with every shard down, want the primary %s", got, primary)
+	}
+}
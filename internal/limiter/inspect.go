@@ -0,0 +1,88 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// KeyState is what one rule has left for a key in the current window.
+type KeyState struct {
+	RuleID        uint   `json:"rule_id"`
+	Strategy      string `json:"strategy"`
+	Limit         int    `json:"limit"`
+	WindowSeconds int    `json:"window_seconds"`
+	Remaining     int    `json:"remaining"`
+}
+
+// Inspect reports each rule's remaining quota for userKey, reading the same
+// counters /check would use for cfgs, without consuming any.
+func Inspect(ctx context.Context, apiKey, endpoint, userKey string, cfgs []RateLimitConfig) ([]KeyState, error) {
+	out := make([]KeyState, len(cfgs))
+	for i, cfg := range cfgs {
+		out[i] = KeyState{
+			RuleID:        cfg.RuleID,
+			Strategy:      string(cfg.Strategy),
+			Limit:         cfg.Limit,
+			WindowSeconds: int(cfg.Window / time.Second),
+		}
+	}
+
+	set := func(i int, v float64) {
+		out[i].Remaining = max(0, int(math.Floor(v)))
+	}
+
+	sel, err := selector()
+	if err != nil {
+		return nil, err
+	}
+	tag := RequestTag(apiKey, userKey)
+	cs, idx, approx, err := ruleCounters(tag, cfgs)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) > 0 {
+		ms, err := multiStoreFor(sel.GetRedisURL(tag))
+		if err != nil {
+			return nil, err
+		}
+		left, err := ms.peek(ctx, cs, time.Now().UnixMilli())
+		if err != nil {
+			return nil, err
+		}
+		for j, v := range left {
+			set(idx[j], v)
+		}
+	}
+	// approximate rules go through their cached Limiter, like in AllowAll
+	for _, i := range approx {
+		lim, err := GetLimiterForKey(apiKey, endpoint, userKey, cfgs[i])
+		if err != nil {
+			return nil, err
+		}
+		v, err := lim.algo.(*approxLimiter).remaining(ctx, userKey)
+		if err != nil {
+			return nil, err
+		}
+		set(i, v)
+	}
+	return out, nil
+}
+
+// remaining counts what no instance has claimed yet plus what this
+// instance holds; other instances' unused claims are not visible.
+func (a *approxLimiter) remaining(ctx context.Context, key string) (float64, error) {
+	win := time.Now().UnixNano() / int64(a.window)
+	claimed, err := a.store.Get(ctx, a.counterKey(key, win))
+	if err != nil {
+		return 0, err
+	}
+
+	a.mu.Lock()
+	var held int64
+	if b, ok := a.local[key]; ok && b.win == win {
+		held = b.tokens
+	}
+	a.mu.Unlock()
+	return math.Max(float64(a.limit)-claimed, 0) + float64(held), nil
+}
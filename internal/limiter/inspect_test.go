@@ -0,0 +1,99 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+// Inspect reports every rule in order, and reading doesn't spend quota.
+func TestInspect(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	ctx := context.Background()
+
+	cfgs := []RateLimitConfig{
+		ruleCfg(1, core.FixedWindow, 5),
+		ruleCfg(2, core.SlidingWindow, 4),
+		ruleCfg(3, core.TokenBucket, 3),
+		ruleCfg(4, core.LeakyBucket, 10),
+	}
+	cfgs[2].Window = time.Minute
+
+	want := func(remaining ...int) {
+		t.Helper()
+		for i := 0; i < 3; i++ { // repeated reads agree
+			st, err := Inspect(ctx, "ns", "/x", "u1", cfgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(st) != len(cfgs) {
+				t.Fatalf("%d states for %d rules", len(st), len(cfgs))
+			}
+			for j, s := range st {
+				c := cfgs[j]
+				meta := KeyState{RuleID: c.RuleID, Strategy: string(c.Strategy), Limit: c.Limit,
+					WindowSeconds: int(c.Window / time.Second), Remaining: remaining[j]}
+				if s != meta {
+					t.Fatalf("rule %d: %+v, want %+v", c.RuleID, s, meta)
+				}
+			}
+		}
+	}
+
+	want(5, 4, 3, 10)
+	for i := 0; i < 2; i++ {
+		if d := AllowAll(ctx, "ns", "/x", "u1", cfgs); !d.Allowed {
+			t.Fatalf("request %d denied: %s", i+1, d.Reason)
+		}
+	}
+	want(3, 2, 1, 8)
+
+	if d := AllowAll(ctx, "ns", "/x", "u1", cfgs); !d.Allowed {
+		t.Fatalf("third request denied: %s", d.Reason)
+	}
+	if d := AllowAll(ctx, "ns", "/x", "u1", cfgs); d.Allowed {
+		t.Fatal("fourth request allowed past the token bucket")
+	}
+	want(2, 1, 0, 7) // the denied request spent nothing
+
+	st, err := Inspect(ctx, "ns", "/x", "u2", cfgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st[0].Remaining != 5 {
+		t.Fatalf("another key's state = %+v, want untouched", st[0])
+	}
+}
+
+// An approximate rule counts what is unclaimed plus this instance's batch.
+func TestInspectApproximate(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	ctx := context.Background()
+	cfg := ruleCfg(9, core.FixedWindow, 100)
+	cfg.Consistency = ConsistencyApproximate
+	cfgs := []RateLimitConfig{cfg}
+
+	for i := 0; i < 3; i++ {
+		if d := AllowAll(ctx, "ns", "/x", "u1", cfgs); !d.Allowed {
+			t.Fatalf("request %d denied: %s", i+1, d.Reason)
+		}
+	}
+	st, err := Inspect(ctx, "ns", "/x", "u1", cfgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st[0].Remaining != 97 || st[0].RuleID != 9 {
+		t.Fatalf("state = %+v, want 97 remaining", st[0])
+	}
+}
+
+func TestInspectUnknownBackend(t *testing.T) {
+	t.Setenv("LIMITER_BACKEND", "carrier-pigeon")
+	resetState()
+	t.Cleanup(resetState)
+	if _, err := Inspect(context.Background(), "ns", "/x", "u1", []RateLimitConfig{ruleCfg(1, core.FixedWindow, 1)}); err == nil {
+		t.Fatal("Inspect without a backend succeeded")
+	}
+}
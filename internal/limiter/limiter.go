@@ -1,114 +1,259 @@
 package limiter
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/AliRizaAynaci/gorl"
 	"github.com/AliRizaAynaci/gorl/core"
+	"golang.org/x/sync/singleflight"
 )
 
 // RateLimitConfig holds the options for rate limiting (strategy, limit, window, vs.) :contentReference[oaicite:0]{index=0}
 type RateLimitConfig struct {
-	Strategy     core.StrategyType
-	KeyBy        core.KeyFuncType
-	Limit        int
-	Window       time.Duration
-	RedisCluster RedisClusterConfig
-	FailOpen     bool // if true, allow requests even if Redis is down
-}
-
-type RedisClusterConfig struct {
-	Nodes    []string `json:"nodes"`
-	Strategy string   `json:"strategy"` // "hash_mod", "consistent_hash"
+	RuleID      uint
+	Namespace   string // stable project identity counters are keyed by; use it as apiKey
+	Strategy    core.StrategyType
+	KeyBy       core.KeyFuncType
+	Limit       int
+	Window      time.Duration
+	FailOpen    bool          // if true, allow requests even if Redis is down
+	FailMode    string        // open | closed | local; empty = derive from FailOpen
+	Consistency string        // "strict" (default) or "approximate"
+	Timeout     time.Duration // deadline for one limiter call; 0 = caller's
 }
 
 // ConfigKey uniquely identifies a limiter configuration including rate and window :contentReference[oaicite:1]{index=1}
 type ConfigKey struct {
 	ApiKey   string        // client’s API key
 	Endpoint string        // requested endpoint
+	RuleID   uint          // rules of the same shape on one endpoint count apart
 	ShardKey string        // the Redis shard URL
 	Limit    int           // number of allowed requests per window
 	Window   time.Duration // time window duration (e.g. 1m, 10s)
+	Strategy core.StrategyType
 	FailOpen bool          // if true, allow requests even if Redis is down
+	FailMode string        // resolved fail mode
+	Timeout  time.Duration // per-call deadline
+}
+
+// algorithm is one rate-limiting strategy bound to a Store.
+type algorithm interface {
+	// Allow consumes one unit for key. A backend failure is returned as
+	// err; the caller resolves it through the rule's fail mode.
+	Allow(ctx context.Context, key string) (allowed bool, err error)
 }
 
+// Limiter is an approximate rule's cached, locally batching limiter.
 type Limiter struct {
-	gorlLimiter core.Limiter
+	algo    algorithm
+	local   algorithm // in-process fallback for fail_local rules
+	mode    string
+	timeout time.Duration
 }
 
+// ErrStrictRule is returned by GetLimiterForKey for a rule that isn't
+// approximate.
+var ErrStrictRule = errors.New("limiter: strict rules are evaluated by AllowAll")
+
 var (
-	limiterCache  = make(map[ConfigKey]*Limiter)
-	shardSelector *ShardSelector
-	mu            sync.Mutex
+	limiterCache  sync.Map // ConfigKey -> *Limiter; lock-free reads on the hot path
+	creating      singleflight.Group
+	shardSelector atomic.Pointer[ShardSelector] // swapped whole on topology change
+	mu            sync.Mutex                    // serialises init and topology changes
 )
 
 // Initialize shard selector
-func InitSharding() {
-	nodes := []string{
-		getEnvOrDefault("REDIS_NODE_1", "redis://localhost:6379/0"),
-		getEnvOrDefault("REDIS_NODE_2", "redis://localhost:6380/0"),
-		getEnvOrDefault("REDIS_NODE_3", "redis://localhost:6381/0"),
+func InitSharding() error {
+	backend = getEnvOrDefault("LIMITER_BACKEND", BackendRedis)
+	switch backend {
+	case BackendRedis, BackendCluster, BackendSentinel, BackendPostgres, BackendMemory:
+	default:
+		return ErrUnknownBackend
+	}
+
+	nodes, err := loadNodes()
+	if err != nil {
+		return err
 	}
 
 	// Clean up empty nodes
-	var validNodes []string
+	var validNodes []Node
 	for _, node := range nodes {
-		if node != "" {
+		if node.URL != "" {
 			validNodes = append(validNodes, node)
 		}
 	}
 
+	// other backends distribute on their own; REDIS_NODE* settings don't apply
+	if backend != BackendRedis {
+		validNodes = []Node{backendNode()}
+	}
+
+	instances = max(1, getEnvInt("RLAAS_INSTANCES", 1))
+	approxBatchPercent = getEnvInt("APPROX_BATCH_PERCENT", 5)
+	approxSync = time.Duration(getEnvInt("APPROX_SYNC_MS", 1000)) * time.Millisecond
+	breakerFailures = max(1, getEnvInt("BREAKER_FAILURES", 5))
+	breakerCooldown = time.Duration(getEnvInt("BREAKER_COOLDOWN_MS", 5000)) * time.Millisecond
+	breakerMinDeadline = time.Duration(getEnvInt("BREAKER_MIN_DEADLINE_MS", 100)) * time.Millisecond
+
 	strategy := getEnvOrDefault("SHARDING_STRATEGY", "hash_mod")
-	shardSelector = NewShardSelector(validNodes, strategy)
+	vnodes := getEnvInt("SHARD_VNODES", defaultVNodes)
+	shardSelector.Store(NewShardSelector(validNodes, strategy, vnodes))
+	return nil
 }
 
+// selector returns the live shard selector, initialising it on first use.
+func selector() (*ShardSelector, error) {
+	if sel := shardSelector.Load(); sel != nil {
+		return sel, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sel := shardSelector.Load(); sel != nil {
+		return sel, nil
+	}
+	if err := InitSharding(); err != nil {
+		return nil, err
+	}
+	return shardSelector.Load(), nil
+}
+
+// GetLimiterForKey returns the cached Limiter of an approximate rule.
+// Strict rules have no per-rule state here; AllowAll evaluates them.
 func GetLimiterForKey(apiKey, endpoint, userKey string, baseConfig RateLimitConfig) (*Limiter, error) {
-	if shardSelector == nil {
-		InitSharding()
+	if baseConfig.Consistency != ConsistencyApproximate {
+		return nil, ErrStrictRule
+	}
+	sel, err := selector()
+	if err != nil {
+		return nil, err
 	}
 
-	shardKey := fmt.Sprintf("%s:%s", apiKey, endpoint)
+	shardKey := apiKey + ":" + endpoint
 
-	redisURL := shardSelector.GetRedisURL(shardKey)
+	redisURL := sel.GetRedisURL(shardKey)
 
 	cfgKey := ConfigKey{
 		ApiKey:   apiKey,
 		Endpoint: endpoint,
+		RuleID:   baseConfig.RuleID,
 		ShardKey: redisURL,
 		Limit:    baseConfig.Limit,
 		Window:   baseConfig.Window,
+		Strategy: baseConfig.Strategy,
 		FailOpen: baseConfig.FailOpen,
+		FailMode: baseConfig.failMode(),
+		Timeout:  baseConfig.Timeout,
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-
-	if limiter, ok := limiterCache[cfgKey]; ok {
-		return limiter, nil
+	if l, ok := limiterCache.Load(cfgKey); ok {
+		return l.(*Limiter), nil
 	}
 
-	gl, err := gorl.New(core.Config{
-		Strategy: baseConfig.Strategy,
-		KeyBy:    baseConfig.KeyBy,
-		Limit:    baseConfig.Limit,
-		Window:   baseConfig.Window,
-		RedisURL: os.Getenv("REDISCLOUD_URL"),
-		FailOpen: baseConfig.FailOpen,
+	// miss: build once per key even when many goroutines race here
+	v, err, _ := creating.Do(fmt.Sprint(cfgKey), func() (any, error) {
+		if l, ok := limiterCache.Load(cfgKey); ok {
+			return l, nil
+		}
+		l, err := newLimiter(cfgKey, baseConfig)
+		if err != nil {
+			return nil, err
+		}
+		limiterCache.Store(cfgKey, l)
+
+		// DrainNode swaps the selector before evicting, so if it ran
+		// while we were building, either it saw our entry or we see
+		// its selector here and back the entry out ourselves.
+		if shardSelector.Load() != sel {
+			limiterCache.Delete(cfgKey)
+		}
+		return l, nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	return v.(*Limiter), nil
+}
+
+func newLimiter(cfgKey ConfigKey, baseConfig RateLimitConfig) (*Limiter, error) {
+	// talk to the shard we selected, through its shared pool
+	store, err := storeFor(cfgKey.ShardKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := cfgKey.ApiKey + ":" + cfgKey.Endpoint + ":" + strconv.FormatUint(uint64(cfgKey.RuleID), 10)
+	l := &Limiter{algo: newApprox(baseConfig, store, ns), mode: cfgKey.FailMode, timeout: cfgKey.Timeout}
+	if cfgKey.FailMode == FailModeLocal {
+		if l.local, err = newLocalFallback(cfgKey.ApiKey, baseConfig); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Allow consumes one unit for key within the rule's deadline. On backend
+// failure (including the deadline expiring or an open circuit) the rule's
+// fail mode decides; fail_local asks the in-process fallback.
+func (l *Limiter) Allow(ctx context.Context, key string) Decision {
+	callCtx := ctx
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
 
-	limiter := &Limiter{gorlLimiter: gl}
-	limiterCache[cfgKey] = limiter
-	return limiter, nil
+	allowed, err := l.algo.Allow(callCtx, key)
+	return decide(allowed, err, l.mode, func() bool {
+		ok, _ := l.local.Allow(ctx, key)
+		return ok
+	})
+}
+
+// Forget drops the cached limiters of the given API keys, so the next check
+// builds them from the current config.
+func Forget(apiKeys ...string) {
+	if len(apiKeys) == 0 {
+		return
+	}
+	drop := make(map[string]bool, len(apiKeys))
+	for _, k := range apiKeys {
+		drop[k] = true
+	}
+	evict(func(k ConfigKey) bool { return drop[k.ApiKey] })
 }
 
-func (l *Limiter) Allow(key string) (bool, error) {
-	return l.gorlLimiter.Allow(key)
+// ForgetPrefix drops the cached limiters of every API key starting with
+// prefix.
+func ForgetPrefix(prefix string) {
+	evict(func(k ConfigKey) bool { return strings.HasPrefix(k.ApiKey, prefix) })
+}
+
+// evict removes matching limiters from the cache and stops their
+// background work. Callers still holding one may keep using it.
+func evict(match func(ConfigKey) bool) {
+	limiterCache.Range(func(k, v any) bool {
+		if match(k.(ConfigKey)) {
+			limiterCache.Delete(k)
+			if s, ok := v.(*Limiter).algo.(interface{ stop() }); ok {
+				s.stop()
+			}
+		}
+		return true
+	})
+}
+
+// IsTimeout reports whether err means a limiter deadline expired.
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -117,3 +262,10 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return defaultValue
+}
@@ -0,0 +1,90 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+// BenchmarkGetLimiterForKeyParallel measures the cached lookup of an
+// approximate rule's limiter under many goroutines; it should scale with
+// cores since hits take no lock.
+func BenchmarkGetLimiterForKeyParallel(b *testing.B) {
+	useBackend(b, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	cfg := ruleCfg(1, core.FixedWindow, 100)
+	cfg.Consistency = ConsistencyApproximate
+
+	const endpoints = 1000
+	names := make([]string, endpoints)
+	for i := range names {
+		names[i] = "/e" + strconv.Itoa(i)
+		if _, err := GetLimiterForKey("proj", names[i], "u", cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var seq atomic.Uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := seq.Add(1) * 7919
+		for pb.Next() {
+			if _, err := GetLimiterForKey("proj", names[i%endpoints], "u", cfg); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+// BenchmarkGetLimiterForKeyParallelMiss has every goroutine race to build
+// the same few fresh limiters, the singleflight path.
+func BenchmarkGetLimiterForKeyParallelMiss(b *testing.B) {
+	useBackend(b, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	cfg := ruleCfg(1, core.FixedWindow, 100)
+	cfg.Consistency = ConsistencyApproximate
+
+	var seq atomic.Uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ep := "/m" + strconv.FormatUint(seq.Add(1)/64, 10) // 64 lookups per fresh key
+			if _, err := GetLimiterForKey("proj", ep, "u", cfg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkAllowAllParallel is the /check path for strict rules: shard
+// selection, the store lookup and one script call per request. The shards
+// are in-process Redis stand-ins, so it measures our side, not the network.
+func BenchmarkAllowAllParallel(b *testing.B) {
+	_, nodes := startShards(b, 3)
+	useBackend(b, map[string]string{"LIMITER_BACKEND": BackendRedis, "REDIS_NODES": nodes})
+	cfgs := []RateLimitConfig{ruleCfg(1, core.FixedWindow, 1<<30), ruleCfg(2, core.TokenBucket, 1<<30)}
+	ctx := context.Background()
+
+	const users = 1000
+	keys := make([]string, users)
+	for i := range keys {
+		keys[i] = "u" + strconv.Itoa(i)
+	}
+
+	var seq atomic.Uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := seq.Add(1) * 7919
+		for pb.Next() {
+			if d := AllowAll(ctx, "proj", "/x", keys[i%users], cfgs); !d.Allowed {
+				b.Fatalf("denied: %+v", d)
+			}
+			i++
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package limiter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+// useBackend points the package at a fresh backend configured by env and
+// restores a clean slate when the test ends.
+func useBackend(t testing.TB, env map[string]string) {
+	t.Helper()
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	resetState()
+	t.Cleanup(resetState)
+	if err := InitSharding(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func resetState() {
+	evict(func(ConfigKey) bool { return true })
+
+	stores.Range(func(url, s any) bool {
+		stores.Delete(url)
+		closeQuietly(s.(Store))
+		return true
+	})
+
+	healthMu.Lock()
+	health = make(map[string]*ShardStatus)
+	publishDown()
+	healthMu.Unlock()
+
+	shardSelector.Store(nil)
+	localOnce = sync.Once{} // fresh fallback counters
+}
+
+func ruleCfg(id uint, s core.StrategyType, limit int) RateLimitConfig {
+	return RateLimitConfig{
+		RuleID:   id,
+		Strategy: s,
+		KeyBy:    core.KeyFuncType("ip"),
+		Limit:    limit,
+		Window:   time.Hour, // no window boundary mid-test
+		FailMode: FailModeClosed,
+	}
+}
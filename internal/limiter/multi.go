@@ -0,0 +1,258 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+// Multi-rule evaluation checks every rule that applies to one request and
+// consumes from all of them or none, so a request denied by its third rule
+// doesn't still eat quota from the first two.
+//
+// All counters of a request share the Redis hash tag {apiKey:userKey}; they
+// therefore land on the same shard (or cluster slot) and are checked and
+// consumed by a single Lua script in one round trip.
+//
+// Compensation: when the counters of one request span several stores
+// (today: approximate rules, which are counted locally, after the shared
+// group), each store is consumed in turn and, on a later deny, the stores
+// already consumed get a refund for exactly the counters they charged.
+// Refunds are best effort: a concurrent check may briefly observe the
+// consumed quota, and a failed refund is lost (the request was denied, so
+// this only under-admits).
+
+// counter is one rule's state in a multi-rule evaluation.
+type counter struct {
+	key    string // hash-tagged base key
+	kind   string // fw | sw | tb | lb
+	limit  int
+	window time.Duration
+	mode   string // fail mode on backend error
+}
+
+// multiStore is implemented by stores that can evaluate several counters
+// at once. consume returns the index of the first denying counter, or -1
+// if all were consumed.
+type multiStore interface {
+	consume(ctx context.Context, cs []counter, nowMs int64) (int, error)
+	refund(ctx context.Context, cs []counter, nowMs int64) error
+	// peek returns what is left on each counter, consuming nothing.
+	peek(ctx context.Context, cs []counter, nowMs int64) ([]float64, error)
+}
+
+// RequestTag is the hash tag shared by every counter of one request.
+func RequestTag(apiKey, userKey string) string {
+	return "{" + apiKey + ":" + userKey + "}"
+}
+
+// AllowAll evaluates cfgs for one request atomically per store. /check
+// calls it for one rule as for several, so a rule's counters don't depend
+// on how many rules share the endpoint. The returned Decision's Rule is
+// the index of the first denying rule.
+// Approximate rules are evaluated locally after the shared counters pass.
+// The call is bounded by the shortest rule Timeout.
+func AllowAll(ctx context.Context, apiKey, endpoint, userKey string, cfgs []RateLimitConfig) Decision {
+	if d := shortestTimeout(cfgs); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	sel, err := selector()
+	if err != nil {
+		return Decision{Reason: ReasonFailClosed, Err: err, Rule: -1}
+	}
+
+	tag := RequestTag(apiKey, userKey)
+	url := sel.GetRedisURL(tag)
+
+	cs, idx, approx, err := ruleCounters(tag, cfgs)
+	if err != nil {
+		return Decision{Reason: ReasonFailClosed, Err: err, Rule: -1}
+	}
+
+	now := time.Now().UnixMilli()
+	groups := []storeGroup{{url: url, counters: cs, idx: idx}}
+	d := consumeGroups(ctx, groups, now)
+	if !d.Allowed {
+		return d
+	}
+
+	for _, i := range approx {
+		lim, err := GetLimiterForKey(apiKey, endpoint, userKey, cfgs[i])
+		if err != nil {
+			refundGroups(groups, now)
+			return Decision{Reason: ReasonFailClosed, Err: err, Rule: i}
+		}
+		ad := lim.Allow(ctx, userKey)
+		if !ad.Allowed {
+			// approximate tokens are local and cheap to lose; refund the
+			// strict counters so the deny doesn't double count
+			refundGroups(groups, now)
+			ad.Rule = i
+			return ad
+		}
+		if ad.Err != nil {
+			d.Reason, d.Err = ad.Reason, ad.Err
+		}
+	}
+	return d
+}
+
+// ruleCounters builds the shared counters of a request's strict rules.
+// cs[i] belongs to cfgs[idx[i]]; approx lists the approximate rules.
+func ruleCounters(tag string, cfgs []RateLimitConfig) (cs []counter, idx, approx []int, err error) {
+	for i, cfg := range cfgs {
+		if cfg.Consistency == ConsistencyApproximate {
+			approx = append(approx, i)
+			continue
+		}
+		c, err := strictCounter(tag, cfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		cs = append(cs, c)
+		idx = append(idx, i)
+	}
+	return cs, idx, approx, nil
+}
+
+// strictCounter is cfg's shared counter for the request tagged tag.
+func strictCounter(tag string, cfg RateLimitConfig) (counter, error) {
+	kind, err := counterKind(cfg.Strategy)
+	if err != nil {
+		return counter{}, err
+	}
+	return counter{
+		key:    "rlaas:" + tag + ":" + strconv.FormatUint(uint64(cfg.RuleID), 10) + ":" + kind,
+		kind:   kind,
+		limit:  cfg.Limit,
+		window: cfg.Window,
+		mode:   cfg.failMode(),
+	}, nil
+}
+
+func shortestTimeout(cfgs []RateLimitConfig) time.Duration {
+	var d time.Duration
+	for _, c := range cfgs {
+		if c.Timeout > 0 && (d == 0 || c.Timeout < d) {
+			d = c.Timeout
+		}
+	}
+	return d
+}
+
+// storeGroup is the set of counters that live on one store.
+type storeGroup struct {
+	url      string
+	counters []counter
+	idx      []int
+}
+
+// consumeGroups consumes each group in turn and refunds earlier groups
+// when a later one denies. A group whose backend fails is resolved by its
+// counters' fail modes.
+func consumeGroups(ctx context.Context, groups []storeGroup, now int64) Decision {
+	d := Decision{Allowed: true, Reason: ReasonAllowed, Rule: -1}
+	for g, grp := range groups {
+		if len(grp.counters) == 0 {
+			continue
+		}
+		ms, err := multiStoreFor(grp.url)
+		if err == nil {
+			var denied int
+			if denied, err = ms.consume(ctx, grp.counters, now); err == nil && denied >= 0 {
+				refundGroups(groups[:g], now)
+				return Decision{Reason: ReasonLimited, Rule: grp.idx[denied], Err: d.Err}
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		local, ok := degradedCounters(grp.counters)
+		if !ok {
+			refundGroups(groups[:g], now)
+			return Decision{Reason: ReasonFailClosed, Err: err, Rule: grp.idx[0]}
+		}
+		d.Err, d.Reason = err, ReasonFailOpen
+		if len(local) == 0 {
+			continue // every rule fails open: treat this group as passed
+		}
+		d.Reason = ReasonFailLocal
+		fb := &genericMulti{store: fallbackStore()}
+		if denied, _ := fb.consume(ctx, local, now); denied >= 0 {
+			refundGroups(groups[:g], now)
+			return Decision{Reason: ReasonFailLocal, Err: err, Rule: grp.idx[denied]}
+		}
+	}
+	return d
+}
+
+// refundGroups runs detached from the request context: the request may
+// already be past its deadline, but the refund should still land.
+func refundGroups(groups []storeGroup, now int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, grp := range groups {
+		if ms, err := multiStoreFor(grp.url); err == nil && len(grp.counters) > 0 {
+			_ = ms.refund(ctx, grp.counters, now)
+		}
+	}
+}
+
+func multiStoreFor(url string) (multiStore, error) {
+	s, err := storeFor(url)
+	if err != nil {
+		return nil, err
+	}
+	if ms, ok := s.(multiStore); ok {
+		return ms, nil
+	}
+	return &genericMulti{store: s}, nil
+}
+
+// degradedCounters maps a group to what still applies while its backend
+// is down: fail_open counters drop out and fail_local ones become
+// per-replica local counters. ok is false if any counter fails closed.
+// Counter positions are kept (open ones get an unlimited stand-in) so a
+// denial index still maps back to the rule.
+func degradedCounters(cs []counter) (local []counter, ok bool) {
+	anyLocal := false
+	out := make([]counter, len(cs))
+	for i, c := range cs {
+		switch c.mode {
+		case FailModeClosed:
+			return nil, false
+		case FailModeLocal:
+			anyLocal = true
+			c = localCounter(c)
+		default:
+			c.key = "local:open:" + c.key
+			c.limit = math.MaxInt32
+		}
+		out[i] = c
+	}
+	if !anyLocal {
+		return nil, true
+	}
+	return out, true
+}
+
+func counterKind(s core.StrategyType) (string, error) {
+	switch s {
+	case core.FixedWindow:
+		return "fw", nil
+	case core.SlidingWindow:
+		return "sw", nil
+	case core.TokenBucket:
+		return "tb", nil
+	case core.LeakyBucket:
+		return "lb", nil
+	}
+	return "", core.ErrUnknownStrategy
+}
@@ -0,0 +1,184 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// genericMulti runs the same algorithm over the plain Store contract for
+// backends without scripting (memory, postgres). It is atomic within this
+// process only; across processes it relies on the compensation path.
+type genericMulti struct{ store Store }
+
+var genericMu sync.Mutex
+
+func (g *genericMulti) consume(ctx context.Context, cs []counter, nowMs int64) (int, error) {
+	genericMu.Lock()
+	defer genericMu.Unlock()
+
+	type write struct {
+		key  string
+		val  float64 // for buckets; windows use Incr
+		ttl  time.Duration
+		incr bool
+	}
+	var plan []write
+
+	for i, c := range cs {
+		win := c.window.Milliseconds()
+		idx := nowMs / win
+		limit := float64(c.limit)
+
+		switch c.kind {
+		case "fw", "sw":
+			k := c.key + ":" + strconv.FormatInt(idx, 10)
+			curr, err := g.store.Get(ctx, k)
+			if err != nil {
+				return -1, err
+			}
+			used := curr + 1
+			ttl := c.window
+			if c.kind == "sw" {
+				prev, err := g.store.Get(ctx, c.key+":"+strconv.FormatInt(idx-1, 10))
+				if err != nil {
+					return -1, err
+				}
+				ratio := float64(nowMs-idx*win) / float64(win)
+				used = prev*(1-ratio) + curr + 1
+				ttl = 2 * c.window
+			}
+			if used > limit {
+				return i, nil
+			}
+			plan = append(plan, write{key: k, ttl: ttl, incr: true})
+
+		default:
+			v, err := g.store.Get(ctx, c.key+":v")
+			if err != nil {
+				return -1, err
+			}
+			t, err := g.store.Get(ctx, c.key+":t")
+			if err != nil {
+				return -1, err
+			}
+			elapsed := float64(nowMs) - t
+			if t == 0 {
+				elapsed = 0
+				if c.kind == "tb" {
+					v = limit
+				}
+			}
+			rate := limit / float64(win)
+			if c.kind == "tb" {
+				if v = min(limit, v+elapsed*rate); v < 1 {
+					return i, nil
+				}
+				v--
+			} else {
+				if v = max(0, v-elapsed*rate); v+1 > limit {
+					return i, nil
+				}
+				v++
+			}
+			plan = append(plan, write{key: c.key, val: v, ttl: c.window})
+		}
+	}
+
+	for _, w := range plan {
+		if w.incr {
+			if _, err := g.store.Incr(ctx, w.key, w.ttl); err != nil {
+				return -1, err
+			}
+			continue
+		}
+		if err := g.store.Set(ctx, w.key+":v", w.val, w.ttl); err != nil {
+			return -1, err
+		}
+		if err := g.store.Set(ctx, w.key+":t", float64(nowMs), w.ttl); err != nil {
+			return -1, err
+		}
+	}
+	return -1, nil
+}
+
+func (g *genericMulti) refund(ctx context.Context, cs []counter, nowMs int64) error {
+	genericMu.Lock()
+	defer genericMu.Unlock()
+
+	for _, c := range cs {
+		switch c.kind {
+		case "fw", "sw":
+			k := c.key + ":" + strconv.FormatInt(nowMs/c.window.Milliseconds(), 10)
+			if n, err := g.store.Get(ctx, k); err != nil || n <= 0 {
+				continue
+			}
+			if _, err := g.store.IncrBy(ctx, k, -1, c.window); err != nil {
+				return err
+			}
+		case "tb", "lb":
+			v, err := g.store.Get(ctx, c.key+":v")
+			if err != nil {
+				return err
+			}
+			if c.kind == "tb" {
+				v = min(float64(c.limit), v+1)
+			} else {
+				v = max(0, v-1)
+			}
+			if err := g.store.Set(ctx, c.key+":v", v, c.window); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *genericMulti) peek(ctx context.Context, cs []counter, nowMs int64) ([]float64, error) {
+	out := make([]float64, len(cs))
+	for i, c := range cs {
+		win := c.window.Milliseconds()
+		idx := nowMs / win
+		limit := float64(c.limit)
+
+		switch c.kind {
+		case "fw", "sw":
+			curr, err := g.store.Get(ctx, c.key+":"+strconv.FormatInt(idx, 10))
+			if err != nil {
+				return nil, err
+			}
+			used := curr
+			if c.kind == "sw" {
+				prev, err := g.store.Get(ctx, c.key+":"+strconv.FormatInt(idx-1, 10))
+				if err != nil {
+					return nil, err
+				}
+				used += prev * (1 - float64(nowMs-idx*win)/float64(win))
+			}
+			out[i] = limit - used
+		default:
+			v, err := g.store.Get(ctx, c.key+":v")
+			if err != nil {
+				return nil, err
+			}
+			t, err := g.store.Get(ctx, c.key+":t")
+			if err != nil {
+				return nil, err
+			}
+			elapsed := float64(nowMs) - t
+			rate := limit / float64(win)
+			switch {
+			case c.kind == "tb" && t == 0:
+				out[i] = limit
+			case c.kind == "tb":
+				out[i] = min(limit, v+elapsed*rate)
+			case t == 0:
+				out[i] = limit
+			default:
+				out[i] = limit - max(0, v-elapsed*rate)
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,164 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// consumeScript checks every counter first and only then consumes them
+// all, so the evaluation is all-or-nothing. Fixed and sliding windows keep
+// one key per window index; buckets keep {v = level, t = last update} in a
+// hash. Returns {1, 0} when allowed or {0, i} for the first denying counter.
+//
+// KEYS[i]        base key of counter i (all share one hash tag)
+// ARGV[1]        now, unix ms
+// ARGV[3i-1..3i+1] kind, limit, window_ms of counter i
+var consumeScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local plan = {}
+for i = 1, #KEYS do
+  local kind  = ARGV[i*3-1]
+  local limit = tonumber(ARGV[i*3])
+  local win   = tonumber(ARGV[i*3+1])
+  local key   = KEYS[i]
+  local idx   = math.floor(now / win)
+
+  if kind == 'fw' then
+    local k = key .. ':' .. idx
+    if tonumber(redis.call('GET', k) or '0') + 1 > limit then return {0, i} end
+    plan[i] = {'incr', k, win}
+  elseif kind == 'sw' then
+    local k = key .. ':' .. idx
+    local curr = tonumber(redis.call('GET', k) or '0')
+    local prev = tonumber(redis.call('GET', key .. ':' .. (idx - 1)) or '0')
+    local ratio = (now - idx * win) / win
+    if prev * (1 - ratio) + curr >= limit then return {0, i} end
+    plan[i] = {'incr', k, 2 * win}
+  else
+    local st = redis.call('HMGET', key, 'v', 't')
+    local t = tonumber(st[2]) or now
+    local rate = limit / win
+    if kind == 'tb' then
+      local v = math.min(limit, (tonumber(st[1]) or limit) + (now - t) * rate)
+      if v < 1 then return {0, i} end
+      plan[i] = {'hset', key, win, v - 1}
+    else
+      local v = math.max(0, (tonumber(st[1]) or 0) - (now - t) * rate)
+      if v + 1 > limit then return {0, i} end
+      plan[i] = {'hset', key, win, v + 1}
+    end
+  end
+end
+
+for i = 1, #KEYS do
+  local p = plan[i]
+  if p[1] == 'incr' then
+    redis.call('INCR', p[2])
+    redis.call('PEXPIRE', p[2], p[3])
+  else
+    redis.call('HSET', p[2], 'v', p[4], 't', now)
+    redis.call('PEXPIRE', p[2], p[3])
+  end
+end
+return {1, 0}
+`)
+
+// refundScript gives back one unit on every counter, using the same window
+// index the consume ran with. Same KEYS/ARGV layout as consumeScript.
+var refundScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+for i = 1, #KEYS do
+  local kind  = ARGV[i*3-1]
+  local limit = tonumber(ARGV[i*3])
+  local key   = KEYS[i]
+  if kind == 'fw' or kind == 'sw' then
+    local k = key .. ':' .. math.floor(now / tonumber(ARGV[i*3+1]))
+    if tonumber(redis.call('GET', k) or '0') > 0 then redis.call('DECR', k) end
+  elseif kind == 'tb' then
+    local v = tonumber(redis.call('HGET', key, 'v'))
+    if v then redis.call('HSET', key, 'v', math.min(limit, v + 1)) end
+  else
+    local v = tonumber(redis.call('HGET', key, 'v'))
+    if v then redis.call('HSET', key, 'v', math.max(0, v - 1)) end
+  end
+end
+return 1
+`)
+
+// peekScript returns what is left on every counter without consuming,
+// as strings (Redis would truncate Lua numbers). Same KEYS/ARGV layout as
+// consumeScript.
+var peekScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local out = {}
+for i = 1, #KEYS do
+  local kind  = ARGV[i*3-1]
+  local limit = tonumber(ARGV[i*3])
+  local win   = tonumber(ARGV[i*3+1])
+  local key   = KEYS[i]
+  local idx   = math.floor(now / win)
+  local left
+
+  if kind == 'fw' then
+    left = limit - tonumber(redis.call('GET', key .. ':' .. idx) or '0')
+  elseif kind == 'sw' then
+    local curr = tonumber(redis.call('GET', key .. ':' .. idx) or '0')
+    local prev = tonumber(redis.call('GET', key .. ':' .. (idx - 1)) or '0')
+    left = limit - (prev * (1 - (now - idx * win) / win) + curr)
+  else
+    local st = redis.call('HMGET', key, 'v', 't')
+    local t = tonumber(st[2]) or now
+    local rate = limit / win
+    if kind == 'tb' then
+      left = math.min(limit, (tonumber(st[1]) or limit) + (now - t) * rate)
+    else
+      left = limit - math.max(0, (tonumber(st[1]) or 0) - (now - t) * rate)
+    end
+  end
+  out[i] = tostring(left)
+end
+return out
+`)
+
+func scriptArgs(cs []counter, nowMs int64) ([]string, []any) {
+	keys := make([]string, len(cs))
+	args := make([]any, 0, 1+3*len(cs))
+	args = append(args, nowMs)
+	for i, c := range cs {
+		keys[i] = c.key
+		args = append(args, c.kind, c.limit, c.window.Milliseconds())
+	}
+	return keys, args
+}
+
+func (s *redisStore) consume(ctx context.Context, cs []counter, nowMs int64) (int, error) {
+	keys, args := scriptArgs(cs, nowMs)
+	res, err := consumeScript.Run(ctx, s.client, keys, args...).Int64Slice()
+	if err != nil {
+		return -1, err
+	}
+	if res[0] == 1 {
+		return -1, nil
+	}
+	return int(res[1]) - 1, nil // Lua is 1-based
+}
+
+func (s *redisStore) refund(ctx context.Context, cs []counter, nowMs int64) error {
+	keys, args := scriptArgs(cs, nowMs)
+	return refundScript.Run(ctx, s.client, keys, args...).Err()
+}
+
+func (s *redisStore) peek(ctx context.Context, cs []counter, nowMs int64) ([]float64, error) {
+	keys, args := scriptArgs(cs, nowMs)
+	res, err := peekScript.Run(ctx, s.client, keys, args...).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float64, len(res))
+	for i, v := range res {
+		out[i], _ = strconv.ParseFloat(v, 64)
+	}
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+// A rule's counters must not depend on how many rules share its endpoint.
+func TestAddingARuleKeepsCounters(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	ctx := context.Background()
+
+	for _, s := range []core.StrategyType{core.FixedWindow, core.SlidingWindow, core.TokenBucket, core.LeakyBucket} {
+		t.Run(string(s), func(t *testing.T) {
+			ns, user := "ns-"+string(s), "10.0.0.1"
+			a, b := ruleCfg(1, s, 3), ruleCfg(2, s, 100)
+
+			for i := 0; i < 2; i++ {
+				if d := AllowAll(ctx, ns, "/x", user, []RateLimitConfig{a}); !d.Allowed {
+					t.Fatalf("request %d with one rule denied: %s", i+1, d.Reason)
+				}
+			}
+			st, err := Inspect(ctx, ns, "/x", user, []RateLimitConfig{a})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st[0].Remaining != 1 {
+				t.Fatalf("remaining = %d, want 1", st[0].Remaining)
+			}
+
+			both := []RateLimitConfig{a, b}
+			if d := AllowAll(ctx, ns, "/x", user, both); !d.Allowed {
+				t.Fatalf("third request after adding a rule denied: %s", d.Reason)
+			}
+			if d := AllowAll(ctx, ns, "/x", user, both); d.Allowed || d.Rule != 0 {
+				t.Fatalf("fourth request = %+v, want denied by the first rule", d)
+			}
+		})
+	}
+}
+
+// A shard that never answers is cut off at the rule's timeout, and the
+// request is then decided by the rule's fail mode.
+func TestSlowStoreFollowsFailModeAfterTimeout(t *testing.T) {
+	cases := []struct {
+		mode    string
+		allowed bool
+		reason  string
+	}{
+		{FailModeOpen, true, ReasonFailOpen},
+		{FailModeClosed, false, ReasonFailClosed},
+		{FailModeLocal, true, ReasonFailLocal},
+	}
+	for _, c := range cases {
+		t.Run(c.mode, func(t *testing.T) {
+			useStore(t, &hangingStore{Store: newMemoryStore()})
+			cfg := ruleCfg(1, core.FixedWindow, 10)
+			cfg.Timeout = 20 * time.Millisecond
+			cfg.FailMode = c.mode
+
+			start := time.Now()
+			d := AllowAll(context.Background(), "proj", "/x", "u", []RateLimitConfig{cfg})
+			if took := time.Since(start); took > 500*time.Millisecond {
+				t.Fatalf("check took %s with a 20ms timeout", took)
+			}
+			if !d.TimedOut() {
+				t.Fatalf("err = %v, want a timeout", d.Err)
+			}
+			if d.Allowed != c.allowed || d.Reason != c.reason {
+				t.Fatalf("decision = %+v, want allowed=%v reason=%s", d, c.allowed, c.reason)
+			}
+		})
+	}
+}
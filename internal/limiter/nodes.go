@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadNodes resolves the shard list, in order of precedence:
+//
+//   - REDIS_NODES_FILE: JSON array of {"url": "...", "weight": 2}
+//   - REDIS_NODES:      comma-separated URLs, each optionally suffixed "|weight"
+//   - REDIS_NODE_1..n:  legacy numbered vars (with REDIS_NODE_n_WEIGHT)
+func loadNodes() ([]Node, error) {
+	if path := os.Getenv("REDIS_NODES_FILE"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var nodes []Node
+		if err := json.Unmarshal(raw, &nodes); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nodes, nil
+	}
+
+	if list := os.Getenv("REDIS_NODES"); list != "" {
+		return parseNodeList(list)
+	}
+
+	var nodes []Node
+	for i := 1; ; i++ {
+		url := os.Getenv("REDIS_NODE_" + strconv.Itoa(i))
+		if url == "" {
+			break
+		}
+		nodes = append(nodes, Node{URL: url, Weight: getEnvInt("REDIS_NODE_"+strconv.Itoa(i)+"_WEIGHT", 1)})
+	}
+	if len(nodes) == 0 {
+		// local docker-compose defaults
+		nodes = []Node{
+			{URL: "redis://localhost:6379/0"},
+			{URL: "redis://localhost:6380/0"},
+			{URL: "redis://localhost:6381/0"},
+		}
+	}
+	return nodes, nil
+}
+
+// parseNodeList parses "url[|weight],url[|weight],...".
+func parseNodeList(list string) ([]Node, error) {
+	var nodes []Node
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		n := Node{URL: item}
+		if url, w, ok := strings.Cut(item, "|"); ok {
+			weight, err := strconv.Atoi(w)
+			if err != nil {
+				return nil, fmt.Errorf("node %q: bad weight", item)
+			}
+			n = Node{URL: url, Weight: weight}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
@@ -0,0 +1,63 @@
+package limiter
+
+import (
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// One Store (connection pool) per node URL, shared by every limiter routed
+// there. Every check looks its store up, so lookups take no lock; the first
+// use of a URL dials once, outside any lock other callers need.
+var (
+	stores  sync.Map // node URL -> Store
+	dialing singleflight.Group
+
+	backend = BackendRedis // set from LIMITER_BACKEND by InitSharding
+)
+
+// storeFor returns the shared Store for a node URL, dialing it on first use.
+// The Store is wrapped in the node's circuit breaker.
+func storeFor(url string) (Store, error) {
+	if s, ok := stores.Load(url); ok {
+		return s.(Store), nil
+	}
+
+	v, err, _ := dialing.Do(url, func() (any, error) {
+		if s, ok := stores.Load(url); ok {
+			return s, nil
+		}
+		s, err := openStore(url)
+		if err != nil {
+			return nil, err
+		}
+		bs := newBreakerStore(s)
+		stores.Store(url, bs)
+		return bs, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(Store), nil
+}
+
+// closeStore closes and forgets the pool for a node URL, unless the node
+// has been re-added in the meantime.
+func closeStore(url string) {
+	for _, n := range Nodes() {
+		if n.URL == url {
+			return
+		}
+	}
+
+	s, ok := stores.LoadAndDelete(url)
+
+	healthMu.Lock()
+	delete(health, url)
+	publishDown()
+	healthMu.Unlock()
+
+	if ok {
+		closeQuietly(s.(Store))
+	}
+}
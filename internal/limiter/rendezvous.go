@@ -0,0 +1,28 @@
+package limiter
+
+import "math"
+
+// rendezvousSelect implements weighted highest-random-weight hashing: every
+// node scores the key and the highest score among nodes accepted by ok
+// wins. Removing a node only moves the keys it owned; cost is O(nodes) per
+// lookup but needs no ring.
+func rendezvousSelect(nodes []Node, key string, ok func(Node) bool) int {
+	best, bestScore := -1, math.Inf(-1)
+	primary, primaryScore := 0, math.Inf(-1)
+	for i, n := range nodes {
+		// map the 64-bit hash into (0,1) and apply the weighted score
+		// -w/ln(u), which gives each node a share proportional to w
+		u := (float64(hash64(n.URL+"|"+key)>>11) + 0.5) / (1 << 53)
+		score := -float64(n.weight()) / math.Log(u)
+		if score > primaryScore {
+			primary, primaryScore = i, score
+		}
+		if score > bestScore && ok(n) {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return primary
+	}
+	return best
+}
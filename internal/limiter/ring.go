@@ -0,0 +1,53 @@
+package limiter
+
+import (
+	"sort"
+	"strconv"
+)
+
+// hashRing is a consistent-hash ring with virtual nodes. Each node owns
+// vnodes×weight points; a key belongs to the first point clockwise from it.
+type hashRing struct {
+	points []uint64 // sorted ring positions
+	owners []int    // owners[i] is the node index of points[i]
+}
+
+func newHashRing(nodes []Node, vnodes int) *hashRing {
+	if vnodes <= 0 {
+		vnodes = defaultVNodes
+	}
+
+	type point struct {
+		hash  uint64
+		owner int
+	}
+	var ps []point
+	for i, n := range nodes {
+		for v := 0; v < vnodes*n.weight(); v++ {
+			ps = append(ps, point{hash64(n.URL + "#" + strconv.Itoa(v)), i})
+		}
+	}
+	sort.Slice(ps, func(a, b int) bool { return ps[a].hash < ps[b].hash })
+
+	r := &hashRing{
+		points: make([]uint64, len(ps)),
+		owners: make([]int, len(ps)),
+	}
+	for i, p := range ps {
+		r.points[i], r.owners[i] = p.hash, p.owner
+	}
+	return r
+}
+
+// walk returns the first node clockwise from h that ok accepts, so a down
+// node's keys spread to its ring successors rather than one neighbour.
+// If no node is acceptable the primary owner is returned.
+func (r *hashRing) walk(h uint64, ok func(owner int) bool) int {
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	for j := 0; j < len(r.points); j++ {
+		if owner := r.owners[(start+j)%len(r.points)]; ok(owner) {
+			return owner
+		}
+	}
+	return r.owners[start%len(r.points)]
+}
@@ -0,0 +1,110 @@
+package limiter
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+const selectorKeys = 100000
+
+func anyNode(Node) bool { return true }
+
+func testNodes(n int) []Node {
+	nodes := make([]Node, n)
+	for i := range nodes {
+		nodes[i] = Node{URL: fmt.Sprintf("redis://shard-%d:6379/0", i)}
+	}
+	return nodes
+}
+
+// owners maps every test key to the URL the selector picks for it.
+func owners(s *ShardSelector) []string {
+	out := make([]string, selectorKeys)
+	for i := range out {
+		out[i] = s.nodes[s.pick(fmt.Sprint("proj:/x:user-", i), anyNode)].URL
+	}
+	return out
+}
+
+func TestSelectorBalance(t *testing.T) {
+	for _, tt := range []struct {
+		strategy  string
+		tolerance float64 // max relative deviation from a node's fair share
+	}{
+		{"consistent_hash", 0.15},
+		{"rendezvous", 0.05},
+	} {
+		t.Run(tt.strategy, func(t *testing.T) {
+			nodes := testNodes(5)
+			nodes[4].Weight = 2 // twice the capacity, twice the keys
+			counts := make(map[string]int)
+			for _, url := range owners(NewShardSelector(nodes, tt.strategy, 0)) {
+				counts[url]++
+			}
+
+			totalWeight := 0
+			for _, n := range nodes {
+				totalWeight += n.weight()
+			}
+			for _, n := range nodes {
+				fair := float64(selectorKeys*n.weight()) / float64(totalWeight)
+				if dev := math.Abs(float64(counts[n.URL])-fair) / fair; dev > tt.tolerance {
+					t.Errorf("%s (weight %d) got %d keys, fair share %.0f (off by %.1f%%)",
+						n.URL, n.weight(), counts[n.URL], fair, dev*100)
+				}
+			}
+		})
+	}
+}
+
+// Adding or removing one of N nodes moves about 1/N of the keys, and only
+// to (or from) that node.
+func TestSelectorRemapping(t *testing.T) {
+	for _, strategy := range []string{"consistent_hash", "rendezvous"} {
+		t.Run(strategy, func(t *testing.T) {
+			nodes := testNodes(6)
+			five := NewShardSelector(nodes[:5], strategy, 0)
+			six := NewShardSelector(nodes, strategy, 0)
+			before, after := owners(five), owners(six)
+
+			added := nodes[5].URL
+			moved := 0
+			for i := range before {
+				if before[i] == after[i] {
+					continue
+				}
+				moved++
+				if after[i] != added {
+					t.Fatalf("key %d moved %s -> %s, not to the added node", i, before[i], after[i])
+				}
+			}
+			checkShare(t, "adding a 6th node", moved, 1.0/6)
+
+			// removing a node from the middle only moves that node's keys
+			var rest []Node
+			rest = append(rest, nodes[:2]...)
+			rest = append(rest, nodes[3:]...)
+			without := owners(NewShardSelector(rest, strategy, 0))
+			moved = 0
+			for i := range after {
+				if after[i] != without[i] {
+					moved++
+					if after[i] != nodes[2].URL {
+						t.Fatalf("key %d moved off %s although only %s was removed", i, after[i], nodes[2].URL)
+					}
+				}
+			}
+			checkShare(t, "removing a middle node", moved, 1.0/6)
+		})
+	}
+}
+
+// checkShare fails unless moved keys are within ±30% of want's share.
+func checkShare(t *testing.T, what string, moved int, want float64) {
+	t.Helper()
+	got := float64(moved) / selectorKeys
+	if got < want*0.7 || got > want*1.3 {
+		t.Errorf("%s moved %.1f%% of keys, want about %.1f%%", what, got*100, want*100)
+	}
+}
@@ -2,65 +2,84 @@ package limiter
 
 import "hash/fnv"
 
+// defaultVNodes is the number of ring points per unit of weight.
+const defaultVNodes = 160
+
+// Node is a single Redis shard.
+type Node struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"` // relative capacity; <=0 means 1
+}
+
+func (n Node) weight() int {
+	if n.Weight <= 0 {
+		return 1
+	}
+	return n.Weight
+}
+
+// ShardSelector is immutable once built; topology changes build a new one.
 type ShardSelector struct {
-	nodes    []string
+	nodes    []Node
 	strategy string
+	vnodes   int
+	ring     *hashRing // built only for consistent_hash
 }
 
-func NewShardSelector(nodes []string, strategy string) *ShardSelector {
-	return &ShardSelector{
+// NewShardSelector builds a selector for the given strategy
+// ("hash_mod", "consistent_hash" or "rendezvous"). vnodes is the number of
+// ring points per unit of weight; <=0 uses the default.
+func NewShardSelector(nodes []Node, strategy string, vnodes int) *ShardSelector {
+	s := &ShardSelector{
 		nodes:    nodes,
 		strategy: strategy,
+		vnodes:   vnodes,
 	}
+	if strategy == "consistent_hash" && len(nodes) > 0 {
+		s.ring = newHashRing(nodes, vnodes)
+	}
+	return s
+}
+
+// Nodes returns a copy of the selector's shard list.
+func (s *ShardSelector) Nodes() []Node {
+	return append([]Node(nil), s.nodes...)
 }
 
+// GetRedisURL returns the shard for key, skipping shards the health
+// checker has marked down. If every shard is down the primary is returned.
 func (s *ShardSelector) GetRedisURL(key string) string {
 	if len(s.nodes) == 0 {
 		return "redis://localhost:6379/0" // fallback
 	}
+	return s.nodes[s.pick(key, func(n Node) bool { return isHealthy(n.URL) })].URL
+}
 
+// pick returns the index of the first node acceptable to ok, in the
+// strategy's preference order for key.
+func (s *ShardSelector) pick(key string, ok func(Node) bool) int {
 	switch s.strategy {
 	case "hash_mod":
-		return s.hashModSelect(key)
+		return s.hashModSelect(key, ok)
 	case "consistent_hash":
-		return s.consistentHashSelect(key)
+		return s.ring.walk(hash64(key), func(i int) bool { return ok(s.nodes[i]) })
+	case "rendezvous":
+		return rendezvousSelect(s.nodes, key, ok)
 	default:
-		return s.nodes[0] // fallback
+		return 0 // fallback
 	}
 }
 
-func (s *ShardSelector) hashModSelect(key string) string {
-	hash := s.hash32(key)
-	nodeIndex := hash % uint32(len(s.nodes))
-	return s.nodes[nodeIndex]
-}
-
-func (s *ShardSelector) consistentHashSelect(key string) string {
-	keyHash := s.hash32(key)
-
-	var selectedNode string
-	minDistance := uint32(^uint32(0)) // max uint32
-
-	for _, node := range s.nodes {
-		nodeHash := s.hash32(node)
-		var distance uint32
-		if nodeHash >= keyHash {
-			distance = nodeHash - keyHash
-		} else {
-			distance = (^uint32(0) - keyHash) + nodeHash
-		}
-
-		if distance < minDistance {
-			minDistance = distance
-			selectedNode = node
+// hashModSelect falls over to the following index when the primary is down.
+func (s *ShardSelector) hashModSelect(key string, ok func(Node) bool) int {
+	n := uint32(len(s.nodes))
+	primary := s.hash32(key) % n
+	for i := uint32(0); i < n; i++ {
+		if idx := (primary + i) % n; ok(s.nodes[idx]) {
+			return int(idx)
 		}
 	}
-
-	if selectedNode == "" {
-		return s.nodes[0]
-	}
-
-	return selectedNode
+	return int(primary)
 }
 
 func (s *ShardSelector) hash32(key string) uint32 {
@@ -68,3 +87,17 @@ func (s *ShardSelector) hash32(key string) uint32 {
 	h.Write([]byte(key))
 	return h.Sum32()
 }
+
+// hash64 is FNV-1a followed by a murmur3 finalizer; plain FNV clusters
+// badly on the near-identical strings used for virtual nodes.
+func hash64(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,123 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AliRizaAynaci/gorl/core"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// startShards runs n local Redis stand-ins and returns them with the
+// REDIS_NODES value listing them.
+func startShards(t testing.TB, n int) ([]*miniredis.Miniredis, string) {
+	t.Helper()
+	var (
+		shards []*miniredis.Miniredis
+		urls   []string
+	)
+	for i := 0; i < n; i++ {
+		m := miniredis.RunT(t)
+		shards = append(shards, m)
+		urls = append(urls, "redis://"+m.Addr()+"/0")
+	}
+	return shards, strings.Join(urls, ",")
+}
+
+func TestChecksSpreadAcrossShards(t *testing.T) {
+	for _, strategy := range []string{"hash_mod", "consistent_hash", "rendezvous"} {
+		t.Run(strategy, func(t *testing.T) {
+			shards, nodes := startShards(t, 3)
+			useBackend(t, map[string]string{
+				"LIMITER_BACKEND":   BackendRedis,
+				"REDIS_NODES":       nodes,
+				"SHARDING_STRATEGY": strategy,
+			})
+			ctx := context.Background()
+			cfg := []RateLimitConfig{ruleCfg(1, core.FixedWindow, 10)}
+
+			const users = 300
+			for i := 0; i < users; i++ {
+				if d := AllowAll(ctx, "proj", "/x", fmt.Sprint("user-", i), cfg); !d.Allowed || d.Err != nil {
+					t.Fatalf("user %d: %+v", i, d)
+				}
+			}
+
+			total := 0
+			for i, m := range shards {
+				n := len(m.Keys())
+				if n < users/10 {
+					t.Errorf("shard %d holds %d of %d keys", i, n, users)
+				}
+				total += n
+			}
+			if total != users {
+				t.Errorf("%d keys across shards, want %d", total, users)
+			}
+		})
+	}
+}
+
+// Every counter lives on the shard the selector picks for its request.
+func TestCountersLandOnSelectedShard(t *testing.T) {
+	shards, nodes := startShards(t, 3)
+	useBackend(t, map[string]string{
+		"LIMITER_BACKEND":   BackendRedis,
+		"REDIS_NODES":       nodes,
+		"SHARDING_STRATEGY": "consistent_hash",
+	})
+	ctx := context.Background()
+	byURL := make(map[string]*miniredis.Miniredis)
+	for _, m := range shards {
+		byURL["redis://"+m.Addr()+"/0"] = m
+	}
+	sel, err := selector()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfgs := []RateLimitConfig{ruleCfg(1, core.SlidingWindow, 5), ruleCfg(2, core.TokenBucket, 5)}
+	for i := 0; i < 20; i++ {
+		user := fmt.Sprint("user-", i)
+		if d := AllowAll(ctx, "proj", "/x", user, cfgs); !d.Allowed {
+			t.Fatalf("%s denied: %+v", user, d)
+		}
+		tag := RequestTag("proj", user)
+		want := byURL[sel.GetRedisURL(tag)]
+		for _, m := range shards {
+			has := false
+			for _, k := range m.Keys() {
+				if strings.Contains(k, tag) {
+					has = true
+				}
+			}
+			if has != (m == want) {
+				t.Errorf("%s: counters on %s, selected %s", user, m.Addr(), want.Addr())
+			}
+		}
+	}
+}
+
+// Strict limits hold across repeated checks on a real Redis protocol
+// stand-in, for each strategy's Lua script.
+func TestLimitsEnforcedOnShard(t *testing.T) {
+	_, nodes := startShards(t, 2)
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendRedis, "REDIS_NODES": nodes})
+	ctx := context.Background()
+
+	for _, s := range []core.StrategyType{core.FixedWindow, core.SlidingWindow, core.TokenBucket, core.LeakyBucket} {
+		t.Run(string(s), func(t *testing.T) {
+			cfg := []RateLimitConfig{ruleCfg(1, s, 3)}
+			for i := 0; i < 3; i++ {
+				if d := AllowAll(ctx, "proj-"+string(s), "/x", "u", cfg); !d.Allowed {
+					t.Fatalf("request %d denied: %+v", i+1, d)
+				}
+			}
+			if d := AllowAll(ctx, "proj-"+string(s), "/x", "u", cfg); d.Allowed {
+				t.Fatal("fourth request allowed")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store is the minimal key-value contract every strategy is written against.
+// It mirrors gorl's storage.Storage so counters stay wire-compatible.
+type Store interface {
+	// Incr atomically increments key by 1, (re)applying ttl.
+	Incr(ctx context.Context, key string, ttl time.Duration) (float64, error)
+	// IncrBy atomically adds n (which may be negative) to key, (re)applying ttl.
+	IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (float64, error)
+	// Get returns the value at key, or 0 if it does not exist.
+	Get(ctx context.Context, key string) (float64, error)
+	// Set stores val at key with ttl.
+	Set(ctx context.Context, key string, val float64, ttl time.Duration) error
+	// Ping checks that the backend is reachable.
+	Ping(ctx context.Context) error
+}
+
+// redisStore is a Store backed by a shared go-redis client.
+type redisStore struct {
+	client redis.UniversalClient
+}
+
+func newRedisStore(client redis.UniversalClient) *redisStore {
+	return &redisStore{client: client}
+}
+
+func (s *redisStore) Incr(ctx context.Context, key string, ttl time.Duration) (float64, error) {
+	return s.IncrBy(ctx, key, 1, ttl)
+}
+
+func (s *redisStore) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (float64, error) {
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		incr = p.IncrBy(ctx, key, n)
+		p.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return float64(incr.Val()), nil
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (float64, error) {
+	str, err := s.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	f, _ := strconv.ParseFloat(str, 64)
+	return f, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, val float64, ttl time.Duration) error {
+	return s.client.Set(ctx, key, val, ttl).Err()
+}
+
+func (s *redisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
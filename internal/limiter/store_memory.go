@@ -0,0 +1,79 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryStore is an in-process Store for single-node dev and unit tests.
+// Counters are lost on restart and are not shared between instances.
+type memoryStore struct {
+	mu   sync.Mutex
+	data map[string]memItem
+}
+
+type memItem struct {
+	val       float64
+	expiresAt time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{data: make(map[string]memItem)}
+	go s.sweep(time.Minute)
+	return s
+}
+
+func (s *memoryStore) Incr(ctx context.Context, key string, ttl time.Duration) (float64, error) {
+	return s.IncrBy(ctx, key, 1, ttl)
+}
+
+func (s *memoryStore) IncrBy(_ context.Context, key string, n int64, ttl time.Duration) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	it, ok := s.data[key]
+	if !ok || now.After(it.expiresAt) {
+		it = memItem{}
+	}
+	it.val += float64(n)
+	it.expiresAt = now.Add(ttl)
+	s.data[key] = it
+	return it.val, nil
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.data[key]
+	if !ok || time.Now().After(it.expiresAt) {
+		return 0, nil
+	}
+	return it.val, nil
+}
+
+func (s *memoryStore) Set(_ context.Context, key string, val float64, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = memItem{val: val, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryStore) Ping(context.Context) error { return nil }
+
+// sweep drops expired keys so idle counters don't accumulate forever.
+func (s *memoryStore) sweep(every time.Duration) {
+	for range time.Tick(every) {
+		now := time.Now()
+		s.mu.Lock()
+		for k, it := range s.data {
+			if now.After(it.expiresAt) {
+				delete(s.data, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryStore()
+
+	if v, _ := s.IncrBy(ctx, "k", 3, time.Minute); v != 3 {
+		t.Fatalf("IncrBy = %v, want 3", v)
+	}
+	if v, _ := s.Incr(ctx, "k", time.Minute); v != 4 {
+		t.Fatalf("Incr = %v, want 4", v)
+	}
+	_ = s.Set(ctx, "short", 7, 20*time.Millisecond)
+	if v, _ := s.Get(ctx, "short"); v != 7 {
+		t.Fatalf("Get = %v, want 7", v)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := s.Get(ctx, "short"); v != 0 {
+		t.Fatalf("Get after ttl = %v, want 0", v)
+	}
+	if v, _ := s.Incr(ctx, "short", time.Minute); v != 1 {
+		t.Fatalf("Incr after ttl = %v, want a fresh count", v)
+	}
+}
+
+func TestMemoryBackendLimits(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	ctx := context.Background()
+
+	for _, s := range []core.StrategyType{core.FixedWindow, core.SlidingWindow, core.TokenBucket, core.LeakyBucket} {
+		t.Run(string(s), func(t *testing.T) {
+			cfg := ruleCfg(1, s, 3)
+			allow := func(cfg RateLimitConfig, user string) bool {
+				return AllowAll(ctx, "proj/"+string(s), "/x", user, []RateLimitConfig{cfg}).Allowed
+			}
+			for i := 0; i < 3; i++ {
+				if !allow(cfg, "u1") {
+					t.Fatalf("request %d denied", i+1)
+				}
+			}
+			if allow(cfg, "u1") {
+				t.Fatal("fourth request allowed")
+			}
+			if !allow(cfg, "u2") {
+				t.Fatal("another user shares u1's counter")
+			}
+			if !allow(ruleCfg(2, s, 3), "u1") {
+				t.Fatal("another rule shares u1's counter")
+			}
+		})
+	}
+}
+
+func TestMemoryBackendWindowResets(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	ctx := context.Background()
+	cfg := ruleCfg(1, core.FixedWindow, 2)
+	cfg.Window = 200 * time.Millisecond
+
+	// start right after a window boundary; windows are aligned to the clock
+	time.Sleep(time.Until(time.Now().Truncate(cfg.Window).Add(cfg.Window)))
+	cs := []RateLimitConfig{cfg}
+	for i := 0; i < 2; i++ {
+		AllowAll(ctx, "proj", "/x", "u", cs)
+	}
+	if d := AllowAll(ctx, "proj", "/x", "u", cs); d.Allowed {
+		t.Fatal("third request in the window allowed")
+	}
+	time.Sleep(cfg.Window)
+	if d := AllowAll(ctx, "proj", "/x", "u", cs); !d.Allowed {
+		t.Fatalf("request in the next window denied: %+v", d)
+	}
+}
+
+func TestMemoryBackendApproximate(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	ctx := context.Background()
+	cfg := ruleCfg(1, core.FixedWindow, 40)
+	cfg.Consistency = ConsistencyApproximate
+
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if AllowAll(ctx, "proj", "/x", "u", []RateLimitConfig{cfg}).Allowed {
+			allowed++
+		}
+	}
+	// one instance claims from the shared counter batch by batch, so it
+	// admits exactly the limit
+	if allowed != 40 {
+		t.Fatalf("allowed %d of 100, want 40", allowed)
+	}
+}
+
+// Approximate rules of the same shape on one endpoint are still two rules.
+func TestApproximateRulesCountApart(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendMemory})
+	ctx := context.Background()
+	a, b := ruleCfg(1, core.FixedWindow, 5), ruleCfg(2, core.FixedWindow, 5)
+	a.Consistency, b.Consistency = ConsistencyApproximate, ConsistencyApproximate
+
+	for i := 0; i < 5; i++ {
+		if !AllowAll(ctx, "proj", "/x", "u", []RateLimitConfig{a}).Allowed {
+			t.Fatalf("rule 1 request %d denied", i+1)
+		}
+	}
+	if !AllowAll(ctx, "proj", "/x", "u", []RateLimitConfig{b}).Allowed {
+		t.Fatal("rule 2 shares rule 1's quota")
+	}
+}
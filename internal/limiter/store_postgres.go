@@ -0,0 +1,76 @@
+package limiter
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Counter is the row layout of the Postgres backend.
+type Counter struct {
+	Key       string    `gorm:"primaryKey"`
+	Value     float64   `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+func (Counter) TableName() string { return "limiter_counters" }
+
+// postgresStore keeps counters in Postgres. It is meant for low-volume
+// tenants where an extra round trip to the primary DB is acceptable.
+type postgresStore struct{ db *gorm.DB }
+
+func newPostgresStore(db *gorm.DB) *postgresStore {
+	s := &postgresStore{db: db}
+	go s.sweep(time.Minute)
+	return s
+}
+
+func (s *postgresStore) Incr(ctx context.Context, key string, ttl time.Duration) (float64, error) {
+	return s.IncrBy(ctx, key, 1, ttl)
+}
+
+func (s *postgresStore) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (float64, error) {
+	var val float64
+	err := s.db.WithContext(ctx).Raw(`
+		INSERT INTO limiter_counters (key, value, expires_at)
+		VALUES (@key, @n, now() + make_interval(secs => @ttl))
+		ON CONFLICT (key) DO UPDATE SET
+			value = CASE WHEN limiter_counters.expires_at < now() THEN @n
+			             ELSE limiter_counters.value + @n END,
+			expires_at = EXCLUDED.expires_at
+		RETURNING value`,
+		sql.Named("key", key), sql.Named("n", n), sql.Named("ttl", ttl.Seconds())).
+		Scan(&val).Error
+	return val, err
+}
+
+func (s *postgresStore) Get(ctx context.Context, key string) (float64, error) {
+	var val float64
+	err := s.db.WithContext(ctx).Raw(`SELECT value FROM limiter_counters WHERE key = ? AND expires_at >= now()`, key).
+		Scan(&val).Error
+	return val, err
+}
+
+func (s *postgresStore) Set(ctx context.Context, key string, val float64, ttl time.Duration) error {
+	return s.db.WithContext(ctx).Exec(`
+		INSERT INTO limiter_counters (key, value, expires_at)
+		VALUES (?, ?, now() + make_interval(secs => ?))
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at`,
+		key, val, ttl.Seconds()).Error
+}
+
+func (s *postgresStore) Ping(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (s *postgresStore) sweep(every time.Duration) {
+	for range time.Tick(every) {
+		s.db.Exec(`DELETE FROM limiter_counters WHERE expires_at < now()`)
+	}
+}
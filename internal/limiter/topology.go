@@ -0,0 +1,207 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/changefeed"
+)
+
+// drainGrace is how long a drained shard's pool stays open so checks that
+// already resolved to it can finish.
+const drainGrace = 30 * time.Second
+
+var (
+	ErrNodeExists   = errors.New("shard node already present")
+	ErrNodeNotFound = errors.New("shard node not found")
+	ErrLastNode     = errors.New("cannot drain the last shard node")
+	ErrNotSharded   = errors.New("topology is managed by the backend, not rlaas")
+	ErrNoTopology   = errors.New("sharding is not initialised yet")
+	ErrBadNodeURL   = errors.New("invalid shard node URL")
+)
+
+// Nodes returns the current shard list.
+func Nodes() []Node {
+	if sel := shardSelector.Load(); sel != nil {
+		return sel.Nodes()
+	}
+	return nil
+}
+
+// ShardNode is one row of the shard list shared by every replica (redis
+// backend only). It is empty until the first AddNode or DrainNode, and
+// REDIS_NODES applies until then.
+type ShardNode struct {
+	URL      string `gorm:"primaryKey"`
+	Weight   int
+	Position int `gorm:"not null"` // hash_mod routing depends on node order
+}
+
+func (ShardNode) TableName() string { return "shard_nodes" }
+
+// AddNode adds a shard on every replica: it is stored, published on the
+// change feed and swapped into this replica's selector.
+func AddNode(n Node) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if backend != BackendRedis {
+		return ErrNotSharded
+	}
+
+	// not selector(): it takes mu itself when initialising
+	cur := shardSelector.Load()
+	if cur == nil {
+		return ErrNoTopology
+	}
+	if _, err := redis.ParseURL(n.URL); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadNodeURL, err)
+	}
+	return changeTopology(cur, func(nodes []Node) ([]Node, error) {
+		for _, existing := range nodes {
+			if existing.URL == n.URL {
+				return nil, ErrNodeExists
+			}
+		}
+		if _, err := storeFor(n.URL); err != nil { // open the pool before routing to it
+			return nil, err
+		}
+		return append(nodes, n), nil
+	})
+}
+
+// DrainNode removes a shard on every replica. New checks stop routing to
+// it immediately; its cached limiters are dropped and its pool is closed
+// after drainGrace.
+func DrainNode(url string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if backend != BackendRedis {
+		return ErrNotSharded
+	}
+
+	// not selector(): it takes mu itself when initialising
+	cur := shardSelector.Load()
+	if cur == nil {
+		return ErrNoTopology
+	}
+	return changeTopology(cur, func(nodes []Node) ([]Node, error) {
+		var kept []Node
+		for _, n := range nodes {
+			if n.URL != url {
+				kept = append(kept, n)
+			}
+		}
+		if len(kept) == len(nodes) {
+			return nil, ErrNodeNotFound
+		}
+		if len(kept) == 0 {
+			return nil, ErrLastNode
+		}
+		return kept, nil
+	})
+}
+
+// ReloadTopology applies the shared shard list, if one is stored and it
+// differs from ours. It runs at start-up and whenever a replica publishes a
+// topology change (this one included).
+func ReloadTopology(ctx context.Context) error {
+	mu.Lock()
+	defer mu.Unlock()
+	db := postgresDB()
+	cur := shardSelector.Load()
+	if backend != BackendRedis || cur == nil || db == nil {
+		return nil
+	}
+
+	nodes, err := loadShardNodes(db.WithContext(ctx))
+	if err != nil || len(nodes) == 0 || slices.Equal(nodes, cur.nodes) {
+		return err
+	}
+	setNodesLocked(cur, nodes)
+	return nil
+}
+
+// changeTopology applies edit to the shard list. With a database the edit
+// runs on the stored list under a table lock, so concurrent changes on two
+// replicas serialise, and the result is published to the others; without
+// one (tests, tools) only this process changes. Called with mu held.
+func changeTopology(cur *ShardSelector, edit func([]Node) ([]Node, error)) error {
+	db := postgresDB()
+	if db == nil {
+		nodes, err := edit(cur.Nodes())
+		if err != nil {
+			return err
+		}
+		setNodesLocked(cur, nodes)
+		return nil
+	}
+
+	var nodes []Node
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`LOCK TABLE shard_nodes IN EXCLUSIVE MODE`).Error; err != nil {
+			return err
+		}
+		stored, err := loadShardNodes(tx)
+		if err != nil {
+			return err
+		}
+		if len(stored) == 0 { // first change: start from REDIS_NODES
+			stored = cur.Nodes()
+		}
+		if nodes, err = edit(stored); err != nil {
+			return err
+		}
+
+		if err := tx.Where("1 = 1").Delete(&ShardNode{}).Error; err != nil {
+			return err
+		}
+		rows := make([]ShardNode, len(nodes))
+		for i, n := range nodes {
+			rows[i] = ShardNode{URL: n.URL, Weight: n.Weight, Position: i}
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+		return changefeed.PublishTopology(tx)
+	})
+	if err != nil {
+		return err
+	}
+	setNodesLocked(cur, nodes)
+	return nil
+}
+
+// setNodesLocked swaps in a selector over nodes and retires the shards that
+// are no longer in it. Called with mu held.
+func setNodesLocked(cur *ShardSelector, nodes []Node) {
+	shardSelector.Store(NewShardSelector(nodes, cur.strategy, cur.vnodes))
+
+	kept := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		kept[n.URL] = true
+	}
+	for _, n := range cur.nodes {
+		if url := n.URL; !kept[url] {
+			evict(func(k ConfigKey) bool { return k.ShardKey == url })
+			time.AfterFunc(drainGrace, func() { closeStore(url) })
+		}
+	}
+}
+
+func loadShardNodes(db *gorm.DB) ([]Node, error) {
+	var rows []ShardNode
+	if err := db.Order("position").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	nodes := make([]Node, len(rows))
+	for i, r := range rows {
+		nodes[i] = Node{URL: r.URL, Weight: r.Weight}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,141 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/AliRizaAynaci/rlaas/internal/changefeed"
+)
+
+// Topology changes before the first check must fail, not deadlock on mu.
+func TestTopologyChangeBeforeInit(t *testing.T) {
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendRedis})
+	shardSelector.Store(nil)
+
+	done := make(chan [2]error)
+	go func() {
+		done <- [2]error{AddNode(Node{URL: "redis://localhost:1/0"}), DrainNode("redis://localhost:1/0")}
+	}()
+	select {
+	case errs := <-done:
+		for _, err := range errs {
+			if !errors.Is(err, ErrNoTopology) {
+				t.Errorf("err = %v, want ErrNoTopology", err)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AddNode/DrainNode deadlocked")
+	}
+}
+
+func TestAddAndDrainNode(t *testing.T) {
+	_, nodes := startShards(t, 3)
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendRedis, "REDIS_NODES": nodes})
+	extra, _ := startShards(t, 1)
+	url := "redis://" + extra[0].Addr() + "/0"
+
+	if err := AddNode(Node{URL: url}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddNode(Node{URL: url}); !errors.Is(err, ErrNodeExists) {
+		t.Fatalf("adding twice: %v", err)
+	}
+	if len(Nodes()) != 4 {
+		t.Fatalf("%d nodes after AddNode, want 4", len(Nodes()))
+	}
+	if err := DrainNode(url); err != nil {
+		t.Fatal(err)
+	}
+	if err := DrainNode(url); !errors.Is(err, ErrNodeNotFound) {
+		t.Fatalf("draining twice: %v", err)
+	}
+	if len(Nodes()) != 3 {
+		t.Fatalf("%d nodes after DrainNode, want 3", len(Nodes()))
+	}
+}
+
+// Topology changes are stored and published, and another replica that still
+// routes by REDIS_NODES picks them up on reload. Needs a scratch database in
+// LIMITER_TEST_POSTGRES_DSN.
+func TestTopologySharedBetweenReplicas(t *testing.T) {
+	dsn := os.Getenv("LIMITER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("LIMITER_TEST_POSTGRES_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&ShardNode{}, &changefeed.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	wipe := func() { db.Where("1 = 1").Delete(&ShardNode{}) }
+	wipe()
+	t.Cleanup(wipe)
+
+	_, nodes := startShards(t, 2)
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendRedis, "REDIS_NODES": nodes})
+	UsePostgres(db)
+	t.Cleanup(func() { UsePostgres(nil) })
+	extra, _ := startShards(t, 1)
+	url := "redis://" + extra[0].Addr() + "/0"
+
+	var before int64
+	db.Model(&changefeed.Change{}).Where("kind = ?", changefeed.KindTopology).Count(&before)
+	if err := AddNode(Node{URL: url}); err != nil {
+		t.Fatal(err)
+	}
+	var rows []ShardNode
+	db.Order("position").Find(&rows)
+	if len(rows) != 3 || rows[2].URL != url {
+		t.Fatalf("stored %+v, want the two REDIS_NODES then %s", rows, url)
+	}
+	var after int64
+	db.Model(&changefeed.Change{}).Where("kind = ?", changefeed.KindTopology).Count(&after)
+	if after != before+1 {
+		t.Fatalf("%d topology changes published, want 1", after-before)
+	}
+
+	// a second replica, started from REDIS_NODES
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendRedis, "REDIS_NODES": nodes})
+	if len(Nodes()) != 2 {
+		t.Fatalf("%d nodes before reload, want 2", len(Nodes()))
+	}
+	if err := ReloadTopology(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := Nodes(); len(got) != 3 || got[2].URL != url {
+		t.Fatalf("nodes after reload = %+v", got)
+	}
+
+	if err := DrainNode(url); err != nil {
+		t.Fatal(err)
+	}
+	if db.Model(&ShardNode{}).Where("url = ?", url).Count(&after); after != 0 {
+		t.Fatal("drained node still stored")
+	}
+}
+
+// Without a database (tests, tools) a change only applies locally and a
+// reload leaves it alone.
+func TestReloadTopologyWithoutDatabase(t *testing.T) {
+	_, nodes := startShards(t, 2)
+	useBackend(t, map[string]string{"LIMITER_BACKEND": BackendRedis, "REDIS_NODES": nodes})
+	extra, _ := startShards(t, 1)
+	if err := AddNode(Node{URL: "redis://" + extra[0].Addr() + "/0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadTopology(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(Nodes()) != 3 {
+		t.Fatalf("%d nodes after reload, want 3", len(Nodes()))
+	}
+}
@@ -0,0 +1,11 @@
+package mail
+
+import "github.com/AliRizaAynaci/rlaas/internal/logging"
+
+// Log writes messages to the log instead of sending them.
+type Log struct{}
+
+func (Log) Send(to, subject, body string) error {
+	logging.L.Info("mail not sent (no SMTP_HOST)", "to", to, "subject", subject, "body", body)
+	return nil
+}
@@ -0,0 +1,28 @@
+// Package mail delivers the emails the service sends, such as invitations.
+package mail
+
+import "os"
+
+// Mailer sends a plain-text email.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// FromEnv returns an SMTP mailer when SMTP_HOST is set, and one that only
+// logs messages otherwise (for development).
+func FromEnv() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return Log{}
+	}
+	return NewSMTP(host, getenv("SMTP_PORT", "587"),
+		os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"),
+		getenv("MAIL_FROM", "no-reply@rlaas.tech"))
+}
+
+func getenv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTP sends through a mail server, authenticating with PLAIN when a
+// username is set.
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTP(host, port, username, password, from string) *SMTP {
+	s := &SMTP{addr: net.JoinHostPort(host, port), from: from}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *SMTP) Send(to, subject, body string) error {
+	msg := "From: " + header(s.from) + "\r\n" +
+		"To: " + header(to) + "\r\n" +
+		"Subject: " + header(subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + strings.ReplaceAll(body, "\n", "\r\n")
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{to}, []byte(msg))
+}
+
+// header keeps a value on one line, so it can't add headers of its own.
+func header(v string) string { return strings.NewReplacer("\r", " ", "\n", " ").Replace(v) }
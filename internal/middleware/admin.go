@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminToken guards operator routes with a static X-Admin-Token header.
+// When ADMIN_TOKEN is unset the routes are disabled entirely.
+func AdminToken() fiber.Handler {
+	token := []byte(getenv("ADMIN_TOKEN", ""))
+
+	return func(c *fiber.Ctx) error {
+		if len(token) == 0 {
+			return fiber.ErrNotFound
+		}
+		got := []byte(c.Get("X-Admin-Token"))
+		if subtle.ConstantTimeCompare(got, token) != 1 {
+			return fiber.ErrUnauthorized
+		}
+		return c.Next()
+	}
+}
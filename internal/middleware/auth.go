@@ -1,16 +1,27 @@
 package middleware
 
 import (
+	"errors"
 	"log"
 	"os"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/AliRizaAynaci/rlaas/internal/logging"
+	"github.com/AliRizaAynaci/rlaas/internal/token"
 )
 
-// Auth validates the JWT found in cookie or Authorization header
-func Auth() fiber.Handler {
+// PATVerifier resolves a personal access token to its user and scopes.
+type PATVerifier interface {
+	Verify(secret string) (uid uint, scopes []string, err error)
+}
+
+// Auth validates the JWT found in cookie or Authorization header, or a
+// personal access token in the header. Tokens also set Locals("scopes");
+// sessions leave it nil, meaning unrestricted.
+func Auth(pats PATVerifier) fiber.Handler {
 	secret := []byte(getenv("JWT_SECRET", "super-secret-change-me"))
 
 	return func(c *fiber.Ctx) error {
@@ -32,6 +43,19 @@ func Auth() fiber.Handler {
 			return fiber.ErrUnauthorized
 		}
 
+		if strings.HasPrefix(tokenStr, token.Prefix) {
+			uid, scopes, err := pats.Verify(tokenStr)
+			if err != nil {
+				if !errors.Is(err, token.ErrInvalid) {
+					logging.L.Warn("personal access token lookup failed", "err", err)
+				}
+				return fiber.ErrUnauthorized
+			}
+			c.Locals("user_id", uid)
+			c.Locals("scopes", scopes)
+			return c.Next()
+		}
+
 		// Parse and validate the token
 		tok, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
 			return secret, nil
@@ -50,6 +74,26 @@ func Auth() fiber.Handler {
 	}
 }
 
+// Scope rejects personal access tokens that don't grant need.
+func Scope(need string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if scopes, ok := c.Locals("scopes").([]string); ok && !token.Allows(scopes, need) {
+			return fiber.NewError(fiber.StatusForbidden, "token lacks scope "+need)
+		}
+		return c.Next()
+	}
+}
+
+// SessionOnly rejects personal access tokens, e.g. for managing tokens.
+func SessionOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("scopes").([]string); ok {
+			return fiber.NewError(fiber.StatusForbidden, "requires a login session, not a token")
+		}
+		return c.Next()
+	}
+}
+
 func getenv(k, def string) string {
 	if v := os.Getenv(k); v != "" {
 		return v
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/AliRizaAynaci/rlaas/internal/token"
+)
+
+// pats maps secrets to the scopes they grant, all for user 7.
+type pats map[string][]string
+
+func (p pats) Verify(secret string) (uint, []string, error) {
+	if scopes, ok := p[secret]; ok {
+		return 7, scopes, nil
+	}
+	return 0, nil, token.ErrInvalid
+}
+
+const (
+	readPAT   = token.Prefix + "read"
+	writePAT  = token.Prefix + "write"
+	adminPAT  = token.Prefix + "admin"
+	revokePAT = token.Prefix + "revoked"
+)
+
+func testApp(t *testing.T) *fiber.App {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+	app := fiber.New()
+	app.Use(Auth(pats{
+		readPAT:  {token.ScopeRead},
+		writePAT: {token.ScopeRulesWrite},
+		adminPAT: {token.ScopeProjectsAdmin},
+	}))
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) }
+	app.Get("/read", Scope(token.ScopeRead), ok)
+	app.Get("/rules", Scope(token.ScopeRulesWrite), ok)
+	app.Get("/projects", Scope(token.ScopeProjectsAdmin), ok)
+	app.Get("/tokens", SessionOnly(), ok)
+	return app
+}
+
+func session(t *testing.T) string {
+	t.Helper()
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 7,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func status(t *testing.T, app *fiber.App, path, bearer string) int {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+// Each scope includes the narrower ones: projects:admin ⊇ rules:write ⊇ read.
+func TestScopeContainment(t *testing.T) {
+	app := testApp(t)
+	tests := []struct {
+		bearer string
+		path   string
+		want   int
+	}{
+		{readPAT, "/read", fiber.StatusNoContent},
+		{readPAT, "/rules", fiber.StatusForbidden},
+		{readPAT, "/projects", fiber.StatusForbidden},
+		{writePAT, "/read", fiber.StatusNoContent},
+		{writePAT, "/rules", fiber.StatusNoContent},
+		{writePAT, "/projects", fiber.StatusForbidden},
+		{adminPAT, "/read", fiber.StatusNoContent},
+		{adminPAT, "/rules", fiber.StatusNoContent},
+		{adminPAT, "/projects", fiber.StatusNoContent},
+		{revokePAT, "/read", fiber.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		if got := status(t, app, tc.path, tc.bearer); got != tc.want {
+			t.Errorf("%s on %s: %d, want %d", tc.bearer, tc.path, got, tc.want)
+		}
+	}
+}
+
+// Sessions are unrestricted by scopes.
+func TestSessionPassesScopes(t *testing.T) {
+	app := testApp(t)
+	for _, path := range []string{"/read", "/rules", "/projects", "/tokens"} {
+		if got := status(t, app, path, session(t)); got != fiber.StatusNoContent {
+			t.Errorf("session on %s: %d", path, got)
+		}
+	}
+}
+
+func TestSessionOnlyRejectsPATs(t *testing.T) {
+	app := testApp(t)
+	for _, pat := range []string{readPAT, writePAT, adminPAT} {
+		if got := status(t, app, "/tokens", pat); got != fiber.StatusForbidden {
+			t.Errorf("%s on a session-only route: %d, want 403", pat, got)
+		}
+	}
+}
@@ -19,6 +19,7 @@ func RequestLogger() fiber.Handler {
 		path := c.Path()
 		ip := c.IP()
 		uid, _ := c.Locals("user_id").(uint)
+		reason, _ := c.Locals("reason").(string) // set by /check
 
 		switch {
 		case status >= 500:
@@ -29,6 +30,7 @@ func RequestLogger() fiber.Handler {
 				"latency_ms", latency.Milliseconds(),
 				"ip", ip,
 				"user_id", uid,
+				"reason", reason,
 				"err", err,
 			)
 		case status >= 400:
@@ -39,6 +41,7 @@ func RequestLogger() fiber.Handler {
 				"latency_ms", latency.Milliseconds(),
 				"ip", ip,
 				"user_id", uid,
+				"reason", reason,
 			)
 		default:
 			logging.L.Info("request completed",
@@ -48,6 +51,7 @@ func RequestLogger() fiber.Handler {
 				"latency_ms", latency.Milliseconds(),
 				"ip", ip,
 				"user_id", uid,
+				"reason", reason,
 			)
 		}
 
@@ -0,0 +1,40 @@
+package org
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProjectNotFound = errors.New("project not found")
+	ErrForbidden       = errors.New("your role in the project's organization does not allow this")
+)
+
+// AuthorizeFunc is Authorize bound to a DB handle.
+type AuthorizeFunc func(pid, uid uint, need string) error
+
+// Authorizer binds Authorize to db.
+func Authorizer(db *gorm.DB) AuthorizeFunc {
+	return func(pid, uid uint, need string) error { return Authorize(db, pid, uid, need) }
+}
+
+// Authorize checks that uid's role in the organization owning project pid
+// includes need. Non-members get ErrForbidden, like members lacking the
+// role.
+func Authorize(db *gorm.DB, pid, uid uint, need string) error {
+	var row struct{ Role string }
+	res := db.Raw(`SELECT COALESCE(m.role, '') AS role
+		FROM projects p
+		LEFT JOIN org_members m ON m.org_id = p.org_id AND m.user_id = ?
+		WHERE p.id = ?`, uid, pid).Scan(&row)
+	switch {
+	case res.Error != nil:
+		return res.Error
+	case res.RowsAffected == 0:
+		return ErrProjectNotFound
+	case !Allows(row.Role, need):
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package org
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
+)
+
+type Handler struct{ svc *Service }
+
+func NewHandler(s *Service) *Handler { return &Handler{s} }
+
+/* helpers */
+func oid(c *fiber.Ctx) uint { id, _ := strconv.Atoi(c.Params("oid")); return uint(id) }
+func uid(c *fiber.Ctx) uint { return c.Locals("user_id").(uint) }
+
+// apiError maps service errors onto the API error envelope.
+func apiError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrUserNotFound), errors.Is(err, ErrNotMember):
+		return httperr.NotFound(err.Error())
+	case errors.Is(err, ErrNotAllowed):
+		return httperr.Forbidden(err.Error())
+	case errors.Is(err, ErrAlreadyMember), errors.Is(err, ErrLastOwner), errors.Is(err, ErrHasProjects):
+		return httperr.Conflict(err.Error())
+	case errors.Is(err, ErrInvalidRole):
+		return httperr.Invalid(map[string]string{"role": err.Error()})
+	}
+	return httperr.Internal(err)
+}
+
+// GET /orgs
+func (h *Handler) List(c *fiber.Ctx) error {
+	ms, err := h.svc.List(uid(c))
+	if err != nil {
+		return httperr.Internal(err)
+	}
+	return c.JSON(ms)
+}
+
+// POST /orgs  { "name": "Acme" }
+func (h *Handler) Create(c *fiber.Ctx) error {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return httperr.BadRequest("body is not valid JSON")
+	}
+	if req.Name == "" || len(req.Name) > 100 {
+		return httperr.Invalid(map[string]string{"name": "must be 1-100 characters"})
+	}
+	o, err := h.svc.Create(uid(c), req.Name)
+	if err != nil {
+		return httperr.Internal(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(o)
+}
+
+// DELETE /orgs/:oid
+func (h *Handler) Delete(c *fiber.Ctx) error {
+	if err := h.svc.Delete(uid(c), oid(c)); err != nil {
+		return apiError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GET /orgs/:oid/members
+func (h *Handler) Members(c *fiber.Ctx) error {
+	ms, err := h.svc.Members(uid(c), oid(c))
+	if err != nil {
+		return apiError(err)
+	}
+	return c.JSON(ms)
+}
+
+// POST /orgs/:oid/members  { "email": "a@b.c", "role": "editor" }
+// The user must have signed in before.
+func (h *Handler) AddMember(c *fiber.Ctx) error {
+	var req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return httperr.BadRequest("body is not valid JSON")
+	}
+	if req.Email == "" {
+		return httperr.Invalid(map[string]string{"email": "is required"})
+	}
+	m, err := h.svc.AddMember(uid(c), oid(c), req.Email, req.Role)
+	if err != nil {
+		return apiError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(m)
+}
+
+// PUT /orgs/:oid/members/:uid  { "role": "admin" }
+func (h *Handler) SetRole(c *fiber.Ctx) error {
+	target, _ := c.ParamsInt("uid")
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return httperr.BadRequest("body is not valid JSON")
+	}
+	if err := h.svc.SetRole(uid(c), oid(c), uint(target), req.Role); err != nil {
+		return apiError(err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// DELETE /orgs/:oid/members/:uid
+func (h *Handler) RemoveMember(c *fiber.Ctx) error {
+	target, _ := c.ParamsInt("uid")
+	if err := h.svc.RemoveMember(uid(c), oid(c), uint(target)); err != nil {
+		return apiError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
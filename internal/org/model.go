@@ -0,0 +1,56 @@
+package org
+
+import "time"
+
+// Roles, from narrowest to widest; each includes the ones before it.
+//
+//	viewer  reads projects, rules, keys and environments
+//	editor  also writes rules, applies configs and promotes
+//	admin   also manages projects, API keys, environments and members
+//	owner   also manages owners and deletes the organization
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+	RoleOwner  = "owner"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3, RoleOwner: 4}
+
+// ValidRole reports whether r is one of the roles above.
+func ValidRole(r string) bool { return roleRank[r] > 0 }
+
+// Allows reports whether role includes need.
+func Allows(role, need string) bool { return ValidRole(role) && roleRank[role] >= roleRank[need] }
+
+// Organization owns projects; users work on them as its members.
+type Organization struct {
+	ID             uint      `json:"id"               gorm:"primaryKey"`
+	Name           string    `json:"name"`
+	PersonalUserID *uint     `json:"personal_user_id" gorm:"uniqueIndex"` // set for a user's personal organization
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type Member struct {
+	OrgID     uint      `json:"org_id"     gorm:"primaryKey"`
+	UserID    uint      `json:"user_id"    gorm:"primaryKey;index"`
+	Role      string    `json:"role"       gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Member) TableName() string { return "org_members" }
+
+// Membership is an organization as seen by one of its members.
+type Membership struct {
+	Organization
+	Role string `json:"role"`
+}
+
+// MemberInfo is a member with the user's details.
+type MemberInfo struct {
+	UserID    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,189 @@
+package org
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormRepo struct{ db *gorm.DB }
+
+func NewGormRepo(db *gorm.DB) Repository { return &gormRepo{db} }
+
+func (r *gormRepo) Create(o *Organization, owner uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(o).Error; err != nil {
+			return err
+		}
+		return tx.Create(&Member{OrgID: o.ID, UserID: owner, Role: RoleOwner}).Error
+	})
+}
+
+func (r *gormRepo) Find(oid uint) (*Organization, error) {
+	var o Organization
+	err := r.db.First(&o, oid).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &o, err
+}
+
+func (r *gormRepo) ListByUser(uid uint) ([]Membership, error) {
+	var ms []Membership
+	return ms, r.db.Raw(`SELECT o.*, m.role FROM organizations o
+		JOIN org_members m ON m.org_id = o.id
+		WHERE m.user_id = ? ORDER BY o.id`, uid).Scan(&ms).Error
+}
+
+func (r *gormRepo) Role(oid, uid uint) (string, error) {
+	var row struct{ Role string }
+	res := r.db.Raw(`SELECT COALESCE(m.role, '') AS role
+		FROM organizations o
+		LEFT JOIN org_members m ON m.org_id = o.id AND m.user_id = ?
+		WHERE o.id = ?`, uid, oid).Scan(&row)
+	switch {
+	case res.Error != nil:
+		return "", res.Error
+	case res.RowsAffected == 0:
+		return "", ErrNotFound
+	}
+	return row.Role, nil
+}
+
+func (r *gormRepo) Members(oid uint) ([]MemberInfo, error) {
+	var ms []MemberInfo
+	return ms, r.db.Raw(`SELECT m.user_id, u.email, u.name, m.role, m.created_at
+		FROM org_members m JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = ? ORDER BY m.created_at`, oid).Scan(&ms).Error
+}
+
+func (r *gormRepo) AddMember(m *Member) error {
+	// a concurrent add of the same user loses here rather than on a 500
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(m)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrAlreadyMember
+	}
+	return res.Error
+}
+
+func (r *gormRepo) SetRole(oid, uid uint, role string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkOwners(tx, oid, uid, role); err != nil {
+			return err
+		}
+		return tx.Model(&Member{}).Where("org_id = ? AND user_id = ?", oid, uid).Update("role", role).Error
+	})
+}
+
+func (r *gormRepo) RemoveMember(oid, uid uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkOwners(tx, oid, uid, ""); err != nil {
+			return err
+		}
+		return tx.Where("org_id = ? AND user_id = ?", oid, uid).Delete(&Member{}).Error
+	})
+}
+
+// checkOwners locks oid's members and checks that giving uid role ("" for
+// removal) leaves an owner. The lock is held until tx ends, so two owners
+// stepping down at once can't both see the other one still there.
+func checkOwners(tx *gorm.DB, oid, uid uint, role string) error {
+	var ms []Member
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("org_id = ?", oid).Find(&ms).Error; err != nil {
+		return err
+	}
+	return keepsOwner(ms, uid, role)
+}
+
+// keepsOwner checks that giving uid role ("" for removal) leaves ms with an
+// owner.
+func keepsOwner(ms []Member, uid uint, role string) error {
+	cur, owners := "", 0
+	for _, m := range ms {
+		if m.UserID == uid {
+			cur = m.Role
+		}
+		if m.Role == RoleOwner {
+			owners++
+		}
+	}
+	switch {
+	case cur == "":
+		return ErrNotMember
+	case cur == RoleOwner && role != RoleOwner && owners <= 1:
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (r *gormRepo) HasProjects(oid uint) (bool, error) {
+	var has bool
+	return has, r.db.Raw(`SELECT EXISTS (SELECT 1 FROM projects WHERE org_id = ?)`, oid).Scan(&has).Error
+}
+
+func (r *gormRepo) Delete(oid uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("org_id = ?", oid).Delete(&Member{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Organization{}, oid).Error
+	})
+}
+
+func (r *gormRepo) Personal(uid uint) (*Organization, error) {
+	var o Organization
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// a concurrent first request may have created it already
+		if err := tx.Exec(`INSERT INTO organizations (name, personal_user_id, created_at)
+			SELECT `+personalName+`, id, ? FROM users WHERE id = ?
+			ON CONFLICT (personal_user_id) DO NOTHING`, time.Now(), uid).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("personal_user_id = ?", uid).First(&o).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&Member{OrgID: o.ID, UserID: uid, Role: RoleOwner}).Error
+	})
+	return &o, err
+}
+
+func (r *gormRepo) UserIDByEmail(email string) (uint, error) {
+	var id uint
+	res := r.db.Raw(`SELECT id FROM users WHERE email = ?`, email).Scan(&id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return 0, ErrUserNotFound
+	}
+	return id, res.Error
+}
+
+// personalName names a user's personal organization, in SQL over users.
+const personalName = `COALESCE(NULLIF(name, ''), email) || ' (personal)'`
+
+// MigrateLegacy moves projects that predate organizations into their
+// creator's personal organization. It is a no-op once done.
+func MigrateLegacy(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Organization{}) {
+		return nil
+	}
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO organizations (name, personal_user_id, created_at)
+			SELECT `+personalName+`, id, ? FROM users u
+			WHERE EXISTS (SELECT 1 FROM projects p WHERE p.user_id = u.id AND COALESCE(p.org_id, 0) = 0)
+			  AND NOT EXISTS (SELECT 1 FROM organizations o WHERE o.personal_user_id = u.id)`, now).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO org_members (org_id, user_id, role, created_at)
+			SELECT o.id, o.personal_user_id, ?, ? FROM organizations o
+			WHERE o.personal_user_id IS NOT NULL
+			ON CONFLICT DO NOTHING`, RoleOwner, now).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE projects p SET org_id = o.id FROM organizations o
+			WHERE o.personal_user_id = p.user_id AND COALESCE(p.org_id, 0) = 0`).Error
+	})
+}
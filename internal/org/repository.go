@@ -0,0 +1,22 @@
+package org
+
+type Repository interface {
+	// Create stores o with owner as its first member.
+	Create(o *Organization, owner uint) error
+	Find(oid uint) (*Organization, error)
+	ListByUser(uid uint) ([]Membership, error)
+	// Role is uid's role in oid, "" for non-members, or ErrNotFound.
+	Role(oid, uid uint) (string, error)
+	Members(oid uint) ([]MemberInfo, error)
+	// AddMember returns ErrAlreadyMember if m's user is already in the org.
+	AddMember(m *Member) error
+	// SetRole and RemoveMember return ErrNotMember when uid is not in oid,
+	// and ErrLastOwner rather than leave oid without an owner.
+	SetRole(oid, uid uint, role string) error
+	RemoveMember(oid, uid uint) error
+	HasProjects(oid uint) (bool, error)
+	Delete(oid uint) error
+	// Personal returns uid's personal organization, creating it if needed.
+	Personal(uid uint) (*Organization, error)
+	UserIDByEmail(email string) (uint, error)
+}
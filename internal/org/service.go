@@ -0,0 +1,179 @@
+package org
+
+import "errors"
+
+var (
+	ErrNotFound      = errors.New("organization not found")
+	ErrUserNotFound  = errors.New("no user with that email; they need to sign in once first")
+	ErrAlreadyMember = errors.New("user is already a member")
+	ErrNotMember     = errors.New("user is not a member")
+	ErrLastOwner     = errors.New("an organization needs at least one owner")
+	ErrHasProjects   = errors.New("organization still has projects")
+	ErrInvalidRole   = errors.New("role must be owner, admin, editor or viewer")
+	ErrNotAllowed    = errors.New("your role in this organization does not allow this")
+)
+
+type Service struct{ repo Repository }
+
+func NewService(r Repository) *Service { return &Service{r} }
+
+// authorize checks uid's role in oid includes need and returns it.
+func (s *Service) authorize(oid, uid uint, need string) (string, error) {
+	role, err := s.repo.Role(oid, uid)
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return "", ErrNotFound // don't reveal other organizations
+	}
+	if !Allows(role, need) {
+		return "", ErrNotAllowed
+	}
+	return role, nil
+}
+
+// RequireRole checks that uid's role in oid includes need.
+func (s *Service) RequireRole(uid, oid uint, need string) error {
+	_, err := s.authorize(oid, uid, need)
+	return err
+}
+
+func (s *Service) List(uid uint) ([]Membership, error) { return s.repo.ListByUser(uid) }
+
+// Create makes an organization with uid as its owner.
+func (s *Service) Create(uid uint, name string) (*Organization, error) {
+	o := &Organization{Name: name}
+	return o, s.repo.Create(o, uid)
+}
+
+// Personal returns uid's personal organization, creating it on first use.
+func (s *Service) Personal(uid uint) (*Organization, error) { return s.repo.Personal(uid) }
+
+// Delete removes an organization without projects; owners only.
+func (s *Service) Delete(uid, oid uint) error {
+	if _, err := s.authorize(oid, uid, RoleOwner); err != nil {
+		return err
+	}
+	has, err := s.repo.HasProjects(oid)
+	if err != nil {
+		return err
+	}
+	if has {
+		return ErrHasProjects
+	}
+	return s.repo.Delete(oid)
+}
+
+func (s *Service) Members(uid, oid uint) ([]MemberInfo, error) {
+	if _, err := s.authorize(oid, uid, RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.Members(oid)
+}
+
+// AddMember adds the user with email to oid. Admins may add anyone but
+// owners; only owners add owners.
+func (s *Service) AddMember(uid, oid uint, email, role string) (*Member, error) {
+	if err := s.CanGrant(uid, oid, role); err != nil {
+		return nil, err
+	}
+	target, err := s.repo.UserIDByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	return s.Join(oid, target, role)
+}
+
+// CanGrant checks that uid may hand out role in oid.
+func (s *Service) CanGrant(uid, oid uint, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	_, err := s.authorize(oid, uid, grantor(role))
+	return err
+}
+
+// IsMember reports whether the user with email belongs to oid.
+func (s *Service) IsMember(oid uint, email string) (bool, error) {
+	uid, err := s.repo.UserIDByEmail(email)
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	role, err := s.repo.Role(oid, uid)
+	return role != "", err
+}
+
+// Get returns organization oid.
+func (s *Service) Get(oid uint) (*Organization, error) { return s.repo.Find(oid) }
+
+// Join makes uid a member of oid with role, without checking who asked;
+// callers authorize first.
+func (s *Service) Join(oid, uid uint, role string) (*Member, error) {
+	m := &Member{OrgID: oid, UserID: uid, Role: role}
+	if err := s.repo.AddMember(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetRole changes target's role. Changes to or from owner need an owner,
+// and the last owner can't step down.
+func (s *Service) SetRole(uid, oid, target uint, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	mine, err := s.authorize(oid, uid, grantor(role))
+	if err != nil {
+		return err
+	}
+	if err := s.canManage(oid, mine, target); err != nil {
+		return err
+	}
+	return s.repo.SetRole(oid, target, role)
+}
+
+// RemoveMember removes target from oid. Anyone may leave; removing others
+// needs an admin, or an owner to remove an owner.
+func (s *Service) RemoveMember(uid, oid, target uint) error {
+	if target == uid {
+		if _, err := s.authorize(oid, uid, RoleViewer); err != nil {
+			return err
+		}
+		return s.repo.RemoveMember(oid, target)
+	}
+	mine, err := s.authorize(oid, uid, RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if err := s.canManage(oid, mine, target); err != nil {
+		return err
+	}
+	return s.repo.RemoveMember(oid, target)
+}
+
+// canManage checks that a member with role mine, already authorized, may
+// change target: owners are managed by owners only. Target is looked up
+// after the caller's own check, so outsiders learn nothing about members.
+func (s *Service) canManage(oid uint, mine string, target uint) error {
+	cur, err := s.repo.Role(oid, target)
+	switch {
+	case err != nil:
+		return err
+	case cur == "":
+		return ErrNotMember
+	case cur == RoleOwner && !Allows(mine, RoleOwner):
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+// grantor is the role needed to hand out role.
+func grantor(role string) string {
+	if role == RoleOwner {
+		return RoleOwner
+	}
+	return RoleAdmin
+}
@@ -0,0 +1,171 @@
+package org
+
+import (
+	"errors"
+	"testing"
+)
+
+// memRepo keeps one organization's members in memory and applies the same
+// owner check as the Postgres repository.
+type memRepo struct {
+	Repository // unused methods panic
+	oid        uint
+	members    []Member
+}
+
+func (r *memRepo) Role(oid, uid uint) (string, error) {
+	if oid != r.oid {
+		return "", ErrNotFound
+	}
+	for _, m := range r.members {
+		if m.UserID == uid {
+			return m.Role, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *memRepo) AddMember(m *Member) error {
+	if role, _ := r.Role(m.OrgID, m.UserID); role != "" {
+		return ErrAlreadyMember
+	}
+	r.members = append(r.members, *m)
+	return nil
+}
+
+func (r *memRepo) SetRole(oid, uid uint, role string) error {
+	if err := keepsOwner(r.members, uid, role); err != nil {
+		return err
+	}
+	for i := range r.members {
+		if r.members[i].UserID == uid {
+			r.members[i].Role = role
+		}
+	}
+	return nil
+}
+
+func (r *memRepo) RemoveMember(oid, uid uint) error {
+	if err := keepsOwner(r.members, uid, ""); err != nil {
+		return err
+	}
+	for i, m := range r.members {
+		if m.UserID == uid {
+			r.members = append(r.members[:i], r.members[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+const (
+	owner  uint = 1
+	admin  uint = 2
+	editor uint = 3
+	viewer uint = 4
+	nobody uint = 99
+)
+
+func newTestService() (*Service, *memRepo) {
+	r := &memRepo{oid: 10, members: []Member{
+		{OrgID: 10, UserID: owner, Role: RoleOwner},
+		{OrgID: 10, UserID: admin, Role: RoleAdmin},
+		{OrgID: 10, UserID: editor, Role: RoleEditor},
+		{OrgID: 10, UserID: viewer, Role: RoleViewer},
+	}}
+	return NewService(r), r
+}
+
+func TestAllowsRoleOrder(t *testing.T) {
+	order := []string{RoleViewer, RoleEditor, RoleAdmin, RoleOwner}
+	for i, role := range order {
+		for j, need := range order {
+			if got := Allows(role, need); got != (i >= j) {
+				t.Errorf("Allows(%s, %s) = %v", role, need, got)
+			}
+		}
+	}
+	if Allows("", RoleViewer) || Allows("root", RoleViewer) {
+		t.Error("a missing or unknown role allows something")
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	tests := []struct {
+		name           string
+		caller, target uint
+		role           string
+		want           error
+	}{
+		{"admin promotes viewer", admin, viewer, RoleEditor, nil},
+		{"admin may not grant owner", admin, viewer, RoleOwner, ErrNotAllowed},
+		{"admin may not demote owner", admin, owner, RoleAdmin, ErrNotAllowed},
+		{"editor may not change roles", editor, viewer, RoleEditor, ErrNotAllowed},
+		{"owner grants owner", owner, admin, RoleOwner, nil},
+		{"last owner can't step down", owner, owner, RoleAdmin, ErrLastOwner},
+		{"unknown role", owner, viewer, "root", ErrInvalidRole},
+		{"target not a member", admin, nobody, RoleEditor, ErrNotMember},
+		// outsiders can't tell members from non-members
+		{"outsider, member target", nobody, viewer, RoleEditor, ErrNotFound},
+		{"outsider, non-member target", nobody, 98, RoleEditor, ErrNotFound},
+		{"viewer, non-member target", viewer, 98, RoleEditor, ErrNotAllowed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := newTestService()
+			if err := s.SetRole(tc.caller, 10, tc.target, tc.role); !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSecondOwnerMayStepDown(t *testing.T) {
+	s, r := newTestService()
+	if err := s.SetRole(owner, 10, admin, RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetRole(owner, 10, owner, RoleAdmin); err != nil {
+		t.Fatalf("owner stepping down with another owner: %v", err)
+	}
+	if err := s.RemoveMember(admin, 10, admin); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("last owner leaving: %v, want ErrLastOwner", err)
+	}
+	if role, _ := r.Role(10, admin); role != RoleOwner {
+		t.Fatalf("role after the refused leave = %q, want owner", role)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	tests := []struct {
+		name           string
+		caller, target uint
+		want           error
+	}{
+		{"viewer leaves", viewer, viewer, nil},
+		{"admin removes editor", admin, editor, nil},
+		{"admin may not remove owner", admin, owner, ErrNotAllowed},
+		{"editor may not remove others", editor, viewer, ErrNotAllowed},
+		{"last owner can't leave", owner, owner, ErrLastOwner},
+		{"outsider, member target", nobody, viewer, ErrNotFound},
+		{"outsider leaving", nobody, nobody, ErrNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := newTestService()
+			if err := s.RemoveMember(tc.caller, 10, tc.target); !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestJoinTwice(t *testing.T) {
+	s, _ := newTestService()
+	if _, err := s.Join(10, 50, RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Join(10, 50, RoleViewer); !errors.Is(err, ErrAlreadyMember) {
+		t.Fatalf("second join: %v, want ErrAlreadyMember", err)
+	}
+}
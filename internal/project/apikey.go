@@ -1,15 +0,0 @@
-package project
-
-import (
-	"crypto/rand"
-	"encoding/hex"
-)
-
-func GenerateAPIKey() (string, error) {
-	bytes := make([]byte, 32) // 256-bit key
-	_, err := rand.Read(bytes)
-	if err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(bytes), nil
-}
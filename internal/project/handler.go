@@ -1,33 +1,45 @@
 package project
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
+	"github.com/AliRizaAynaci/rlaas/internal/org"
 )
 
 type Handler struct{ svc *Service }
 
 func NewHandler(s *Service) *Handler { return &Handler{s} }
 
-// POST /projects  { "project_name": "My API" }
+// apiError maps service errors onto the API error envelope.
+func apiError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, org.ErrNotFound):
+		return httperr.NotFound(err.Error())
+	case errors.Is(err, ErrForbidden), errors.Is(err, org.ErrNotAllowed):
+		return httperr.Forbidden(err.Error())
+	}
+	return httperr.Internal(err)
+}
+
+// POST /projects  { "project_name": "My API", "org_id": 3 }
+// Without org_id the project goes to the user's personal organization.
 func (h *Handler) Create(c *fiber.Ctx) error {
 	var req struct {
 		ProjectName string `json:"project_name"`
+		OrgID       uint   `json:"org_id"`
 	}
 	if err := c.BodyParser(&req); err != nil || req.ProjectName == "" {
 		return fiber.ErrBadRequest
 	}
 
-	apikey, err := GenerateAPIKey()
-	if err != nil {
-		return fiber.ErrInternalServerError
-	}
-
 	uid := c.Locals("user_id").(uint)
-	p, err := h.svc.Create(uid, req.ProjectName, apikey)
+	p, err := h.svc.Create(uid, req.OrgID, req.ProjectName)
 	if err != nil {
-		return fiber.ErrInternalServerError
+		return apiError(err)
 	}
 	return c.Status(fiber.StatusCreated).JSON(p)
 }
@@ -42,13 +54,31 @@ func (h *Handler) List(c *fiber.Ctx) error {
 	return c.JSON(list)
 }
 
+// PATCH /projects/:pid  { "limiter_timeout_ms": 200 }
+func (h *Handler) Update(c *fiber.Ctx) error {
+	pid, _ := strconv.Atoi(c.Params("pid"))
+	uid := c.Locals("user_id").(uint)
+
+	var req struct {
+		LimiterTimeoutMs *int `json:"limiter_timeout_ms"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.LimiterTimeoutMs == nil || *req.LimiterTimeoutMs < 0 {
+		return fiber.ErrBadRequest
+	}
+
+	if err := h.svc.SetLimiterTimeout(uid, uint(pid), *req.LimiterTimeoutMs); err != nil {
+		return apiError(err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
 // DELETE /projects/:pid
 func (h *Handler) Delete(c *fiber.Ctx) error {
 	pid, _ := strconv.Atoi(c.Params("pid"))
 	uid := c.Locals("user_id").(uint)
 
 	if err := h.svc.Delete(uid, uint(pid)); err != nil {
-		return fiber.ErrForbidden // ya sahibi değil ya proje yok
+		return apiError(err)
 	}
 	return c.SendStatus(fiber.StatusNoContent)
 }
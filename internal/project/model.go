@@ -3,14 +3,20 @@ package project
 import (
 	"time"
 
+	"github.com/AliRizaAynaci/rlaas/internal/apikey"
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
 	"github.com/AliRizaAynaci/rlaas/internal/rule"
 )
 
 type Project struct {
-	ID        uint        `json:"id" gorm:"primaryKey"`
-	UserID    uint        `json:"user_id" gorm:"index"`
-	Name      string      `json:"name"`
-	APIKey    string      `json:"api_key" gorm:"uniqueIndex"`
-	CreatedAt time.Time   `json:"created_at"`
-	Rules     []rule.Rule `json:"rules" gorm:"constraint:OnDelete:CASCADE"`
+	ID               uint                      `json:"id" gorm:"primaryKey"`
+	OrgID            uint                      `json:"org_id" gorm:"index"`  // owning organization
+	UserID           uint                      `json:"user_id" gorm:"index"` // creator
+	Name             string                    `json:"name"`
+	CounterKey       string                    `json:"-"`                  // namespaces rate-limit state, shared by all keys
+	LimiterTimeoutMs int                       `json:"limiter_timeout_ms"` // default rule deadline; 0 = LIMITER_TIMEOUT_MS
+	CreatedAt        time.Time                 `json:"created_at"`
+	Rules            []rule.Rule               `json:"rules" gorm:"constraint:OnDelete:CASCADE"`
+	APIKeys          []apikey.Key              `json:"api_keys" gorm:"constraint:OnDelete:CASCADE"`
+	Environments     []environment.Environment `json:"environments" gorm:"constraint:OnDelete:CASCADE"`
 }
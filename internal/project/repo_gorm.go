@@ -1,6 +1,10 @@
 package project
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/changefeed"
+)
 
 type gormRepo struct{ db *gorm.DB }
 
@@ -14,7 +18,9 @@ func (r *gormRepo) ListByUser(uid uint) ([]Project, error) {
 	var list []Project
 	return list, r.db.
 		Preload("Rules").
-		Where("user_id = ?", uid).
+		Preload("APIKeys").
+		Preload("Environments").
+		Where("org_id IN (SELECT org_id FROM org_members WHERE user_id = ?)", uid).
 		Order("created_at DESC").
 		Find(&list).Error
 }
@@ -25,6 +31,26 @@ func (r *gormRepo) FindByID(id uint) (*Project, error) {
 	return &p, err
 }
 
-func (r *gormRepo) Delete(id, uid uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, uid).Delete(&Project{}).Error
+// UpdateLimiterTimeout reports false when no project matched id.
+func (r *gormRepo) UpdateLimiterTimeout(id uint, ms int) (bool, error) {
+	var ok bool
+	return ok, r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Project{}).
+			Where("id = ?", id).
+			Update("limiter_timeout_ms", ms)
+		if ok = res.RowsAffected > 0; res.Error != nil || !ok {
+			return res.Error
+		}
+		return changefeed.Publish(tx, id)
+	})
+}
+
+func (r *gormRepo) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&Project{}, id)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return changefeed.Publish(tx, id)
+	})
 }
@@ -2,7 +2,9 @@ package project
 
 type Repository interface {
 	Create(*Project) error
+	// ListByUser returns the projects of the user's organizations.
 	ListByUser(uint) ([]Project, error)
 	FindByID(id uint) (*Project, error)
-	Delete(id, userID uint) error
+	Delete(id uint) error
+	UpdateLimiterTimeout(id uint, ms int) (bool, error)
 }
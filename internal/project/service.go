@@ -1,30 +1,103 @@
 package project
 
-type Service struct{ repo Repository }
+import (
+	"crypto/rand"
+	"encoding/hex"
 
-func NewService(r Repository) *Service { return &Service{r} }
+	"gorm.io/gorm"
 
-func (s *Service) Create(userID uint, name string, apikey string) (*Project, error) {
+	"github.com/AliRizaAynaci/rlaas/internal/apikey"
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+var (
+	ErrNotFound  = org.ErrProjectNotFound
+	ErrForbidden = org.ErrForbidden
+)
+
+type Service struct {
+	repo     Repository
+	db       *gorm.DB // role checks
+	orgs     *org.Service
+	onChange func(projectID uint)
+}
+
+func NewService(r Repository, db *gorm.DB, orgs *org.Service) *Service {
+	return &Service{repo: r, db: db, orgs: orgs}
+}
+
+// OnChange registers fn to run after a project was updated or deleted.
+func (s *Service) OnChange(fn func(projectID uint)) { s.onChange = fn }
+
+func (s *Service) changed(pid uint) {
+	if s.onChange != nil {
+		s.onChange(pid)
+	}
+}
+
+// Create makes a project in organization orgID (0 = the user's personal
+// one) with the default environment and one API key in it, named
+// "default"; the key's secret is only in the returned project. Needs the
+// admin role in the organization.
+func (s *Service) Create(userID, orgID uint, name string) (*Project, error) {
+	if orgID == 0 {
+		o, err := s.orgs.Personal(userID)
+		if err != nil {
+			return nil, err
+		}
+		orgID = o.ID
+	}
+	if err := s.orgs.RequireRole(userID, orgID, org.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	key, err := apikey.New("default")
+	if err != nil {
+		return nil, err
+	}
+	ns := make([]byte, 16)
+	if _, err := rand.Read(ns); err != nil {
+		return nil, err
+	}
 	p := &Project{
-		UserID: userID,
-		Name:   name,
-		APIKey: apikey,
+		OrgID:        orgID,
+		UserID:       userID,
+		Name:         name,
+		CounterKey:   hex.EncodeToString(ns),
+		APIKeys:      []apikey.Key{key},
+		Environments: []environment.Environment{{Name: environment.Default}},
 	}
 	return p, s.repo.Create(p)
 }
 
+// List returns the projects of every organization the user belongs to.
 func (s *Service) List(userID uint) ([]Project, error) {
 	return s.repo.ListByUser(userID)
 }
 
-func (s *Service) UserOwns(pid, uid uint) (bool, error) {
-	p, err := s.repo.FindByID(pid)
+func (s *Service) SetLimiterTimeout(userID, projectID uint, ms int) error {
+	if err := org.Authorize(s.db, projectID, userID, org.RoleAdmin); err != nil {
+		return err
+	}
+	ok, err := s.repo.UpdateLimiterTimeout(projectID, ms)
 	if err != nil {
-		return false, err
+		return err
+	}
+	if !ok {
+		return ErrNotFound
 	}
-	return p.UserID == uid, nil
+	s.changed(projectID)
+	return nil
 }
 
 func (s *Service) Delete(userID, projectID uint) error {
-	return s.repo.Delete(projectID, userID)
+	if err := org.Authorize(s.db, projectID, userID, org.RoleAdmin); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(projectID); err != nil {
+		return err
+	}
+	s.changed(projectID)
+	return nil
 }
@@ -0,0 +1,142 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Config is one environment's declarative rule set, as exported and
+// applied via /projects/:pid/config. Rules are identified by (endpoint, strategy,
+// window_seconds): changing any of those replaces the rule, which resets
+// its counters just as a new window would.
+type Config struct {
+	Rules []Spec `json:"rules" yaml:"rules"`
+}
+
+// Spec is the user-editable part of a Rule.
+type Spec struct {
+	Endpoint      string `json:"endpoint"       yaml:"endpoint"`
+	Strategy      string `json:"strategy"       yaml:"strategy"`
+	KeyBy         string `json:"key_by"         yaml:"key_by,omitempty"`
+	LimitCount    int    `json:"limit_count"    yaml:"limit_count"`
+	WindowSeconds int    `json:"window_seconds" yaml:"window_seconds"`
+	FailOpen      bool   `json:"fail_open"      yaml:"fail_open,omitempty"`
+	FailMode      string `json:"fail_mode"      yaml:"fail_mode,omitempty"`
+	Consistency   string `json:"consistency"    yaml:"consistency,omitempty"`
+	TimeoutMs     int    `json:"timeout_ms"     yaml:"timeout_ms,omitempty"`
+}
+
+func specOf(r Rule) Spec {
+	return Spec{
+		Endpoint:      r.Endpoint,
+		Strategy:      r.Strategy,
+		KeyBy:         r.KeyBy,
+		LimitCount:    r.LimitCount,
+		WindowSeconds: r.WindowSeconds,
+		FailOpen:      r.FailOpen,
+		FailMode:      r.FailMode,
+		Consistency:   r.Consistency,
+		TimeoutMs:     r.TimeoutMs,
+	}
+}
+
+func (s Spec) rule(pid uint, env string) Rule {
+	return Rule{
+		ProjectID:     pid,
+		Environment:   env,
+		Endpoint:      s.Endpoint,
+		Strategy:      s.Strategy,
+		KeyBy:         s.KeyBy,
+		LimitCount:    s.LimitCount,
+		WindowSeconds: s.WindowSeconds,
+		FailOpen:      s.FailOpen,
+		FailMode:      s.FailMode,
+		Consistency:   s.Consistency,
+		TimeoutMs:     s.TimeoutMs,
+	}
+}
+
+// Plan is what applying a Config changes.
+type Plan struct {
+	Create    []Rule       `json:"create"`
+	Update    []RuleChange `json:"update"`
+	Delete    []Rule       `json:"delete"`
+	Unchanged int          `json:"unchanged"`
+}
+
+// RuleChange is one rule updated in place.
+type RuleChange struct {
+	ID       uint              `json:"id"`
+	Endpoint string            `json:"endpoint"`
+	Changes  map[string]change `json:"changes"`
+
+	before, after Rule
+}
+
+func identity(r Rule) string {
+	return fmt.Sprintf("%s %s %d", r.Endpoint, r.Strategy, r.WindowSeconds)
+}
+
+// rules validates cfg and turns it into rules of project pid's environment
+// env. Field errors are keyed by position, e.g. "rules[2].limit_count".
+func (c *Config) rules(pid uint, env string) ([]Rule, error) {
+	bad := make(map[string]string)
+	seen := make(map[string]int)
+	out := make([]Rule, len(c.Rules))
+	for i, s := range c.Rules {
+		at := "rules[" + strconv.Itoa(i) + "]"
+		out[i] = s.rule(pid, env)
+		if err := out[i].Validate(); err != nil {
+			for f, msg := range err.(*ValidationError).Fields {
+				bad[at+"."+f] = msg
+			}
+			continue
+		}
+		if j, dup := seen[identity(out[i])]; dup {
+			bad[at] = "same endpoint, strategy and window_seconds as rules[" + strconv.Itoa(j) + "]"
+		}
+		seen[identity(out[i])] = i
+	}
+	if len(bad) > 0 {
+		return nil, &ValidationError{Fields: bad}
+	}
+	return out, nil
+}
+
+// planConfig diffs the existing rules against the desired ones. Existing
+// duplicates of one identity beyond the first (by ID) are deleted.
+func planConfig(existing, desired []Rule) *Plan {
+	p := &Plan{Create: []Rule{}, Update: []RuleChange{}, Delete: []Rule{}}
+
+	have := make(map[string]Rule)
+	for _, r := range existing {
+		if _, dup := have[identity(r)]; dup {
+			p.Delete = append(p.Delete, r)
+			continue
+		}
+		have[identity(r)] = r
+	}
+
+	for _, want := range desired {
+		cur, ok := have[identity(want)]
+		if !ok {
+			p.Create = append(p.Create, want)
+			continue
+		}
+		delete(have, identity(want))
+
+		want.ID, want.CreatedAt = cur.ID, cur.CreatedAt
+		if d := diff(&cur, &want); len(d) > 0 {
+			p.Update = append(p.Update, RuleChange{ID: cur.ID, Endpoint: cur.Endpoint, Changes: d, before: cur, after: want})
+		} else {
+			p.Unchanged++
+		}
+	}
+
+	for _, r := range existing {
+		if left, ok := have[identity(r)]; ok && left.ID == r.ID {
+			p.Delete = append(p.Delete, r)
+		}
+	}
+	return p
+}
@@ -0,0 +1,130 @@
+package rule
+
+import (
+	"errors"
+	"testing"
+)
+
+func spec(endpoint string, limit int) Spec {
+	return Spec{Endpoint: endpoint, Strategy: "fixed_window", LimitCount: limit, WindowSeconds: 60}
+}
+
+func TestConfigRules(t *testing.T) {
+	cfg := &Config{Rules: []Spec{spec("/a", 10), spec("/b", 20)}}
+	rs, err := cfg.rules(7, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 2 {
+		t.Fatalf("%d rules, want 2", len(rs))
+	}
+	for _, r := range rs {
+		if r.ProjectID != 7 || r.Environment != "staging" || r.KeyBy != "api_key" {
+			t.Errorf("rule %+v: want project 7, env staging and defaults filled", r)
+		}
+	}
+}
+
+// Errors name the position of the offending spec.
+func TestConfigRulesErrorsByPosition(t *testing.T) {
+	cfg := &Config{Rules: []Spec{spec("/a", 10), spec("/b", 0), spec("/a", 99)}}
+	_, err := cfg.rules(1, "prod")
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	if _, ok := ve.Fields["rules[1].limit_count"]; !ok {
+		t.Errorf("fields %v: want rules[1].limit_count", ve.Fields)
+	}
+	if _, ok := ve.Fields["rules[2]"]; !ok {
+		t.Errorf("fields %v: want rules[2] as a duplicate of rules[0]", ve.Fields)
+	}
+	if len(ve.Fields) != 2 {
+		t.Errorf("fields %v: want exactly 2", ve.Fields)
+	}
+}
+
+// stored gives rules IDs as if they had been created.
+func stored(t *testing.T, specs ...Spec) []Rule {
+	t.Helper()
+	rs, err := (&Config{Rules: specs}).rules(1, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range rs {
+		rs[i].ID = uint(i + 1)
+	}
+	return rs
+}
+
+func TestPlanConfig(t *testing.T) {
+	existing := stored(t, spec("/keep", 10), spec("/edit", 10), spec("/drop", 10))
+	edited := spec("/edit", 50)
+	edited.TimeoutMs = 200
+	desired := stored(t, spec("/keep", 10), edited, spec("/new", 5))
+
+	p := planConfig(existing, desired)
+	if len(p.Create) != 1 || p.Create[0].Endpoint != "/new" {
+		t.Errorf("create = %+v, want /new", p.Create)
+	}
+	if len(p.Delete) != 1 || p.Delete[0].Endpoint != "/drop" {
+		t.Errorf("delete = %+v, want /drop", p.Delete)
+	}
+	if p.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", p.Unchanged)
+	}
+	if len(p.Update) != 1 {
+		t.Fatalf("update = %+v, want /edit", p.Update)
+	}
+	u := p.Update[0]
+	if u.ID != 2 || u.Endpoint != "/edit" || len(u.Changes) != 2 {
+		t.Fatalf("update = %+v, want rule 2 with limit_count and timeout_ms changed", u)
+	}
+	if c := u.Changes["limit_count"]; c.From != 10 || c.To != 50 {
+		t.Errorf("limit_count change = %+v, want 10 -> 50", c)
+	}
+	if u.after.ID != 2 {
+		t.Errorf("update would write rule %d, want it in place as 2", u.after.ID)
+	}
+}
+
+// Changing a rule's window is a new identity: delete and create, not update.
+func TestPlanConfigReplacesOnIdentityChange(t *testing.T) {
+	existing := stored(t, spec("/a", 10))
+	moved := spec("/a", 10)
+	moved.WindowSeconds = 3600
+	p := planConfig(existing, stored(t, moved))
+	if len(p.Create) != 1 || len(p.Delete) != 1 || len(p.Update) != 0 {
+		t.Fatalf("plan = %+v, want one create and one delete", p)
+	}
+}
+
+// Only the first (lowest ID) of several existing rules with one identity is
+// kept.
+func TestPlanConfigDeletesDuplicates(t *testing.T) {
+	existing := stored(t, spec("/a", 10))
+	dup := existing[0]
+	dup.ID = 9
+	p := planConfig(append(existing, dup), stored(t, spec("/a", 10)))
+	if len(p.Delete) != 1 || p.Delete[0].ID != 9 || p.Unchanged != 1 {
+		t.Fatalf("plan = %+v, want duplicate 9 deleted and 1 kept", p)
+	}
+}
+
+// Applying the same config again changes nothing.
+func TestPlanConfigIdempotent(t *testing.T) {
+	specs := []Spec{spec("/a", 10), spec("/b", 20), spec("/c", 30)}
+	p := planConfig(nil, stored(t, specs...))
+	if len(p.Create) != 3 {
+		t.Fatalf("first apply creates %d, want 3", len(p.Create))
+	}
+
+	desired, err := (&Config{Rules: specs}).rules(1, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = planConfig(stored(t, specs...), desired)
+	if len(p.Create)+len(p.Update)+len(p.Delete) != 0 || p.Unchanged != 3 {
+		t.Fatalf("re-apply plan = %+v, want nothing but 3 unchanged", p)
+	}
+}
@@ -1,9 +1,17 @@
 package rule
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
 )
 
 type Handler struct{ svc *Service }
@@ -11,15 +19,49 @@ type Handler struct{ svc *Service }
 func NewHandler(s *Service) *Handler { return &Handler{s} }
 
 /* helpers */
-func pid(c *fiber.Ctx) uint { id, _ := strconv.Atoi(c.Params("pid")); return uint(id) }
-func rid(c *fiber.Ctx) uint { id, _ := strconv.Atoi(c.Params("rid")); return uint(id) }
+func pid(c *fiber.Ctx) uint   { id, _ := strconv.Atoi(c.Params("pid")); return uint(id) }
+func rid(c *fiber.Ctx) uint   { id, _ := strconv.Atoi(c.Params("rid")); return uint(id) }
+func env(c *fiber.Ctx) string { return c.Query("env", environment.Default) }
+
+// apiError maps service errors onto the API error envelope.
+func apiError(err error) error {
+	var ve *ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return httperr.Invalid(ve.Fields)
+	case errors.Is(err, ErrProjectNotFound):
+		return httperr.NotFound("project not found")
+	case errors.Is(err, ErrNotFound):
+		return httperr.NotFound("rule not found")
+	case errors.Is(err, ErrNoVersion):
+		return httperr.NotFound(err.Error())
+	case errors.Is(err, ErrDeletedVersion):
+		return httperr.Conflict(err.Error())
+	case errors.Is(err, ErrForbidden):
+		return httperr.Forbidden(err.Error())
+	case errors.Is(err, environment.ErrNotFound):
+		return httperr.NotFound(err.Error())
+	case errors.Is(err, ErrSameEnvironment):
+		return httperr.BadRequest(err.Error())
+	}
+	return httperr.Internal(err)
+}
 
-/* GET /projects/:pid/rules */
+/* GET /projects/:pid/rules?env=staging  (all environments without env) */
 func (h *Handler) List(c *fiber.Ctx) error {
 	uid := c.Locals("user_id").(uint)
 	out, err := h.svc.List(uid, pid(c))
 	if err != nil {
-		return fiber.ErrForbidden
+		return apiError(err)
+	}
+	if e := c.Query("env"); e != "" {
+		in := out
+		out = []Rule{}
+		for _, r := range in {
+			if r.Environment == e {
+				out = append(out, r)
+			}
+		}
 	}
 	return c.JSON(out)
 }
@@ -29,11 +71,11 @@ func (h *Handler) Create(c *fiber.Ctx) error {
 	uid := c.Locals("user_id").(uint)
 	var in Rule
 	if err := c.BodyParser(&in); err != nil {
-		return fiber.ErrBadRequest
+		return httperr.BadRequest("body is not a valid rule: " + err.Error())
 	}
 	r, err := h.svc.Add(uid, pid(c), &in)
 	if err != nil {
-		return fiber.ErrForbidden
+		return apiError(err)
 	}
 	return c.Status(fiber.StatusCreated).JSON(r)
 }
@@ -43,12 +85,12 @@ func (h *Handler) Update(c *fiber.Ctx) error {
 	uid := c.Locals("user_id").(uint)
 	var in Rule
 	if err := c.BodyParser(&in); err != nil {
-		return fiber.ErrBadRequest
+		return httperr.BadRequest("body is not a valid rule: " + err.Error())
 	}
 	in.ID = rid(c)
 	in.ProjectID = pid(c)
 	if err := h.svc.Update(uid, &in); err != nil {
-		return fiber.ErrForbidden
+		return apiError(err)
 	}
 	return c.SendStatus(fiber.StatusOK)
 }
@@ -57,7 +99,117 @@ func (h *Handler) Update(c *fiber.Ctx) error {
 func (h *Handler) Delete(c *fiber.Ctx) error {
 	uid := c.Locals("user_id").(uint)
 	if err := h.svc.Delete(uid, pid(c), rid(c)); err != nil {
-		return fiber.ErrForbidden
+		return apiError(err)
 	}
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+/* GET /projects/:pid/rules/:rid/versions */
+func (h *Handler) Versions(c *fiber.Ctx) error {
+	uid := c.Locals("user_id").(uint)
+	out, err := h.svc.Versions(uid, pid(c), rid(c))
+	if err != nil {
+		return apiError(err)
+	}
+	return c.JSON(out)
+}
+
+/* POST /projects/:pid/rules/:rid/versions/:ver/rollback */
+func (h *Handler) Rollback(c *fiber.Ctx) error {
+	uid := c.Locals("user_id").(uint)
+	n, err := strconv.Atoi(c.Params("ver"))
+	if err != nil || n <= 0 {
+		return httperr.BadRequest("version must be a positive integer")
+	}
+	r, err := h.svc.Rollback(uid, pid(c), rid(c), n)
+	if err != nil {
+		return apiError(err)
+	}
+	return c.JSON(r)
+}
+
+/* GET /projects/:pid/config?env=prod&format=yaml|json */
+func (h *Handler) ExportConfig(c *fiber.Ctx) error {
+	uid := c.Locals("user_id").(uint)
+	cfg, err := h.svc.ExportConfig(uid, pid(c), env(c))
+	if err != nil {
+		return apiError(err)
+	}
+	if !wantsYAML(c) {
+		return c.JSON(cfg)
+	}
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		return httperr.Internal(err)
+	}
+	c.Set(fiber.HeaderContentType, "application/yaml")
+	return c.Send(out)
+}
+
+/* PUT /projects/:pid/config?env=prod&dry_run=true  (body: YAML or JSON Config) */
+func (h *Handler) ApplyConfig(c *fiber.Ctx) error {
+	uid := c.Locals("user_id").(uint)
+
+	var cfg Config
+	var err error
+	if strings.Contains(c.Get(fiber.HeaderContentType), "yaml") {
+		dec := yaml.NewDecoder(bytes.NewReader(c.Body()))
+		dec.KnownFields(true)
+		err = dec.Decode(&cfg)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(c.Body()))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+	}
+	if err != nil {
+		return httperr.BadRequest("body is not a valid config: " + err.Error())
+	}
+
+	dryRun := c.QueryBool("dry_run")
+	plan, err := h.svc.ApplyConfig(uid, pid(c), env(c), &cfg, dryRun)
+	if err != nil {
+		return apiError(err)
+	}
+	return c.JSON(fiber.Map{"dry_run": dryRun, "plan": plan})
+}
+
+/* POST /projects/:pid/environments/:env/promote  { "to": "prod", "dry_run": true } */
+func (h *Handler) Promote(c *fiber.Ctx) error {
+	uid := c.Locals("user_id").(uint)
+	var req struct {
+		To     string `json:"to"`
+		DryRun bool   `json:"dry_run"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return httperr.BadRequest("body is not valid JSON")
+	}
+	if req.To == "" {
+		return httperr.Invalid(map[string]string{"to": "is required"})
+	}
+	plan, err := h.svc.Promote(uid, pid(c), c.Params("env"), req.To, req.DryRun)
+	if err != nil {
+		return apiError(err)
+	}
+	return c.JSON(fiber.Map{"from": c.Params("env"), "to": req.To, "dry_run": req.DryRun, "plan": plan})
+}
+
+/* GET /projects/:pid/environments/:env/drift?to=prod */
+// Drift is what promoting :env to ?to (default prod) would change.
+func (h *Handler) Drift(c *fiber.Ctx) error {
+	uid := c.Locals("user_id").(uint)
+	from, to := c.Params("env"), c.Query("to", environment.Default)
+	plan, err := h.svc.Promote(uid, pid(c), from, to, true)
+	if err != nil {
+		return apiError(err)
+	}
+	inSync := len(plan.Create)+len(plan.Update)+len(plan.Delete) == 0
+	return c.JSON(fiber.Map{"from": from, "to": to, "in_sync": inSync, "plan": plan})
+}
+
+// wantsYAML picks the export format: ?format= first, then Accept.
+func wantsYAML(c *fiber.Ctx) bool {
+	if f := c.Query("format"); f != "" {
+		return f == "yaml" || f == "yml"
+	}
+	return strings.Contains(c.Get(fiber.HeaderAccept), "yaml")
+}
@@ -5,11 +5,15 @@ import "time"
 type Rule struct {
 	ID            uint      `json:"id"            gorm:"primaryKey"`
 	ProjectID     uint      `json:"project_id"    gorm:"index"`
+	Environment   string    `json:"environment"   gorm:"not null;default:prod;index"`
 	Endpoint      string    `json:"endpoint"`
 	Strategy      string    `json:"strategy"` // token_bucket | sliding_window | …
 	KeyBy         string    `json:"key_by"`   // api_key | ip | user_id
 	LimitCount    int       `json:"limit_count"`
 	WindowSeconds int       `json:"window_seconds"`
-	FailOpen      bool      `json:"fail_open"` // if true, allow requests even if rate limit is exceeded
+	FailOpen      bool      `json:"fail_open"`                         // if true, allow requests even if rate limit is exceeded
+	FailMode      string    `json:"fail_mode"`                         // open | closed | local; empty = from fail_open
+	Consistency   string    `json:"consistency" gorm:"default:strict"` // strict | approximate
+	TimeoutMs     int       `json:"timeout_ms"`                        // limiter deadline; 0 = project default
 	CreatedAt     time.Time `json:"created_at"`
 }
@@ -1,22 +1,189 @@
 package rule
 
-import "gorm.io/gorm"
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/AliRizaAynaci/rlaas/internal/changefeed"
+)
 
 type gormRepo struct{ db *gorm.DB }
 
 func NewGormRepo(db *gorm.DB) Repository { return &gormRepo{db} }
 
-func (r *gormRepo) Create(m *Rule) error { return r.db.Create(m).Error }
+// Writes record a version and publish a config change in the same
+// transaction.
+
+func (r *gormRepo) Create(uid uint, m *Rule) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		return record(tx, uid, ActionCreate, nil, m)
+	})
+}
 
 func (r *gormRepo) ListByProject(pid uint) ([]Rule, error) {
 	var rs []Rule
 	return rs, r.db.Where("project_id=?", pid).Find(&rs).Error
 }
 
-func (r *gormRepo) Update(m *Rule) error {
-	return r.db.Where("id=? AND project_id=?", m.ID, m.ProjectID).Updates(m).Error
+func (r *gormRepo) Update(uid uint, m *Rule) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		before, err := lock(tx, m.ID, m.ProjectID)
+		if err != nil {
+			return err
+		}
+		// a PUT replaces the rule, so false, 0 and "" must be written too
+		if err := tx.Model(&Rule{}).Where("id=?", m.ID).Select(editable).Updates(m).Error; err != nil {
+			return err
+		}
+		var after Rule
+		if err := tx.First(&after, m.ID).Error; err != nil {
+			return err
+		}
+		return record(tx, uid, ActionUpdate, before, &after)
+	})
+}
+
+func (r *gormRepo) Delete(uid, id, pid uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		before, err := lock(tx, id, pid)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&Rule{}, id).Error; err != nil {
+			return err
+		}
+		return record(tx, uid, ActionDelete, before, nil)
+	})
+}
+
+func (r *gormRepo) Versions(id, pid uint) ([]Version, error) {
+	var vs []Version
+	return vs, r.db.Where("rule_id=? AND project_id=?", id, pid).
+		Order("version DESC").Find(&vs).Error
+}
+
+func (r *gormRepo) Version(id, pid uint, n int) (*Version, error) {
+	var v Version
+	return &v, r.db.Where("rule_id=? AND project_id=? AND version=?", id, pid, n).
+		First(&v).Error
+}
+
+func (r *gormRepo) Restore(uid uint, m *Rule) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		before, err := lock(tx, m.ID, m.ProjectID)
+		switch {
+		case err == ErrNotFound: // deleted since: bring it back under its old ID
+			before = nil
+			if err := tx.Create(m).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			if err := tx.Model(&Rule{}).Where("id=?", m.ID).Select(editable).Updates(m).Error; err != nil {
+				return err
+			}
+		}
+		var after Rule
+		if err := tx.First(&after, m.ID).Error; err != nil {
+			return err
+		}
+		*m = after
+		return record(tx, uid, ActionRollback, before, &after)
+	})
 }
 
-func (r *gormRepo) Delete(id, pid uint) error {
-	return r.db.Where("id=? AND project_id=?", id, pid).Delete(&Rule{}).Error
+func (r *gormRepo) Apply(uid, pid uint, env string, desired []Rule, dryRun bool) (*Plan, error) {
+	var plan *Plan
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []Rule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("project_id=? AND environment=?", pid, env).Order("id").Find(&existing).Error; err != nil {
+			return err
+		}
+		plan = planConfig(existing, desired)
+		if dryRun {
+			return nil
+		}
+
+		for i := range plan.Delete {
+			d := &plan.Delete[i]
+			if err := tx.Delete(&Rule{}, d.ID).Error; err != nil {
+				return err
+			}
+			if err := record(tx, uid, ActionDelete, d, nil); err != nil {
+				return err
+			}
+		}
+		for _, u := range plan.Update {
+			if err := tx.Model(&Rule{}).Where("id=?", u.ID).Select(editable).Updates(&u.after).Error; err != nil {
+				return err
+			}
+			if err := record(tx, uid, ActionUpdate, &u.before, &u.after); err != nil {
+				return err
+			}
+		}
+		for i := range plan.Create {
+			c := &plan.Create[i]
+			if err := tx.Create(c).Error; err != nil {
+				return err
+			}
+			if err := record(tx, uid, ActionCreate, nil, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return plan, err
+}
+
+// lock reads a rule for update, so concurrent writers number their
+// versions one after the other.
+func lock(tx *gorm.DB, id, pid uint) (*Rule, error) {
+	var m Rule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id=? AND project_id=?", id, pid).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// record appends the next version of a rule and publishes the change.
+// Exactly one of before and after may be nil.
+func record(tx *gorm.DB, uid uint, action string, before, after *Rule) error {
+	cur := after
+	if cur == nil {
+		cur = before
+	}
+	snap, err := json.Marshal(cur)
+	if err != nil {
+		return err
+	}
+	d, err := json.Marshal(diff(before, after))
+	if err != nil {
+		return err
+	}
+
+	var n int
+	if err := tx.Model(&Version{}).Where("rule_id=?", cur.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&n).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&Version{
+		RuleID:    cur.ID,
+		ProjectID: cur.ProjectID,
+		Version:   n + 1,
+		Action:    action,
+		UserID:    uid,
+		Snapshot:  snap,
+		Diff:      d,
+	}).Error; err != nil {
+		return err
+	}
+	return changefeed.Publish(tx, cur.ProjectID)
 }
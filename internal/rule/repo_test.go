@@ -0,0 +1,198 @@
+package rule
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/AliRizaAynaci/rlaas/internal/changefeed"
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+)
+
+// projectRow is enough of a project for the rules foreign key.
+type projectRow struct {
+	ID   uint
+	Name string
+}
+
+func (projectRow) TableName() string { return "projects" }
+
+// testRepo needs a scratch database in LIMITER_TEST_POSTGRES_DSN, e.g. the
+// docker-compose Postgres. Rules go to a new project, removed afterwards.
+func testRepo(t *testing.T) (Repository, *gorm.DB, uint) {
+	dsn := os.Getenv("LIMITER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("LIMITER_TEST_POSTGRES_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&projectRow{}, &Rule{}, &Version{}, &changefeed.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	p := projectRow{Name: "rule-test"}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("project_id = ?", p.ID).Delete(&Version{})
+		db.Where("project_id = ?", p.ID).Delete(&Rule{})
+		db.Delete(&p)
+	})
+	return NewGormRepo(db), db, p.ID
+}
+
+func newRule(t *testing.T, r Repository, pid uint, limit int) *Rule {
+	t.Helper()
+	rs, err := (&Config{Rules: []Spec{spec("/pay", limit)}}).rules(pid, environment.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Create(1, &rs[0]); err != nil {
+		t.Fatal(err)
+	}
+	return &rs[0]
+}
+
+func snapshot(t *testing.T, v *Version) Rule {
+	t.Helper()
+	var m Rule
+	if err := json.Unmarshal(v.Snapshot, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// Concurrent writers wait for each other's lock, so versions are numbered
+// one after the other without gaps or duplicates.
+func TestVersionsIncrementUnderLock(t *testing.T) {
+	r, _, pid := testRepo(t)
+	m := newRule(t, r, pid, 1)
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(limit int) {
+			defer wg.Done()
+			u := *m
+			u.LimitCount = limit
+			errs <- r.Update(uint(limit), &u)
+		}(i + 2)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vs, err := r.Versions(m.ID, pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != writers+1 {
+		t.Fatalf("%d versions, want %d", len(vs), writers+1)
+	}
+	for i, v := range vs { // newest first
+		if want := writers + 1 - i; v.Version != want {
+			t.Fatalf("versions %v: position %d is %d, want %d", vs, i, v.Version, want)
+		}
+	}
+	// each update's diff starts where the previous one left off
+	for i := len(vs) - 2; i >= 0; i-- {
+		var d map[string]change
+		if err := json.Unmarshal(vs[i].Diff, &d); err != nil {
+			t.Fatal(err)
+		}
+		prev := snapshot(t, &vs[i+1]).LimitCount
+		if from := d["limit_count"].From; from != float64(prev) {
+			t.Fatalf("version %d changed limit from %v, but version %d left %d", vs[i].Version, from, vs[i+1].Version, prev)
+		}
+	}
+}
+
+func TestRestoreExisting(t *testing.T) {
+	r, db, pid := testRepo(t)
+	m := newRule(t, r, pid, 10)
+	u := *m
+	u.LimitCount = 99
+	if err := r.Update(1, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	v1, err := r.Version(m.ID, pid, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := snapshot(t, v1)
+	if err := r.Restore(2, &back); err != nil {
+		t.Fatal(err)
+	}
+	var got Rule
+	db.First(&got, m.ID)
+	if got.LimitCount != 10 || back.LimitCount != 10 {
+		t.Fatalf("restored limit = %d (returned %d), want 10", got.LimitCount, back.LimitCount)
+	}
+
+	v3, err := r.Version(m.ID, pid, 3)
+	if err != nil || v3.Action != ActionRollback || v3.UserID != 2 {
+		t.Fatalf("version 3 = %+v, %v; want a rollback by user 2", v3, err)
+	}
+	var d map[string]change
+	_ = json.Unmarshal(v3.Diff, &d)
+	if len(d) != 1 || d["limit_count"].From != float64(99) || d["limit_count"].To != float64(10) {
+		t.Fatalf("rollback diff = %s", v3.Diff)
+	}
+}
+
+// A deleted rule comes back under its old ID, so its history continues.
+func TestRestoreDeleted(t *testing.T) {
+	r, db, pid := testRepo(t)
+	m := newRule(t, r, pid, 10)
+	if err := r.Delete(1, m.ID, pid); err != nil {
+		t.Fatal(err)
+	}
+
+	v1, err := r.Version(m.ID, pid, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := snapshot(t, v1)
+	if err := r.Restore(2, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.ID != m.ID {
+		t.Fatalf("restored under ID %d, want %d", back.ID, m.ID)
+	}
+	var got Rule
+	if err := db.First(&got, m.ID).Error; err != nil || got.Endpoint != "/pay" || got.LimitCount != 10 {
+		t.Fatalf("restored rule = %+v, %v", got, err)
+	}
+
+	vs, err := r.Versions(m.ID, pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, v := range vs {
+		actions = append(actions, v.Action)
+	}
+	if len(vs) != 3 || actions[0] != ActionRollback || actions[1] != ActionDelete || actions[2] != ActionCreate {
+		t.Fatalf("history = %v, want rollback, delete, create", actions)
+	}
+
+	// new rules keep getting fresh IDs past the restored one
+	n := newRule(t, r, pid, 5)
+	if n.ID <= m.ID {
+		t.Fatalf("new rule got ID %d, restored rule has %d", n.ID, m.ID)
+	}
+}
@@ -1,8 +1,19 @@
 package rule
 
+// Writes take the acting user's ID, recorded as the version author.
 type Repository interface {
-	Create(*Rule) error
+	Create(uid uint, r *Rule) error
 	ListByProject(uint) ([]Rule, error)
-	Update(*Rule) error
-	Delete(ruleID, projectID uint) error
+	Update(uid uint, r *Rule) error
+	Delete(uid, ruleID, projectID uint) error
+
+	Versions(ruleID, projectID uint) ([]Version, error)
+	Version(ruleID, projectID uint, version int) (*Version, error)
+	// Restore makes the rule equal to snapshot again, recreating it if it
+	// was deleted, and records that as a rollback version.
+	Restore(uid uint, snapshot *Rule) error
+
+	// Apply makes the rules of one of the project's environments match
+	// desired in one transaction; with dryRun it only computes the plan.
+	Apply(uid, projectID uint, env string, desired []Rule, dryRun bool) (*Plan, error)
 }
@@ -1,63 +1,220 @@
 package rule
 
 import (
+	"encoding/json"
 	"errors"
+	"sort"
 
 	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+	"github.com/AliRizaAynaci/rlaas/internal/org"
 )
 
-var ErrNotFound = gorm.ErrRecordNotFound
+var (
+	ErrNotFound        = gorm.ErrRecordNotFound
+	ErrProjectNotFound = org.ErrProjectNotFound
+	ErrForbidden       = org.ErrForbidden
+	ErrNoVersion       = errors.New("rule version not found")
+	ErrDeletedVersion  = errors.New("version is a deletion; roll back to an earlier one")
+	ErrSameEnvironment = errors.New("source and target environment are the same")
+)
 
 type Service struct {
-	repo Repository
-	db   *gorm.DB // we only need raw DB for owner check
+	repo      Repository
+	authorize org.AuthorizeFunc
+	checkEnv  func(pid uint, env string) error // environment.Check
+	onChange  func(projectID uint)
 }
 
 func NewService(r Repository, db *gorm.DB) *Service {
-	return &Service{repo: r, db: db}
+	return &Service{
+		repo:      r,
+		authorize: org.Authorizer(db),
+		checkEnv:  func(pid uint, env string) error { return environment.Check(db, pid, env) },
+	}
 }
 
-/* verifies project.user_id == uid */
-func (s *Service) assertOwner(pid, uid uint) error {
-	var ownerID uint
-	if err := s.db.
-		Raw(`SELECT user_id FROM projects WHERE id = ?`, pid).
-		Scan(&ownerID).Error; err != nil {
-		return err
-	}
-	if ownerID != uid {
-		return errors.New("forbidden")
+// OnChange registers fn to run after a project's rules were written.
+func (s *Service) OnChange(fn func(projectID uint)) { s.onChange = fn }
+
+func (s *Service) changed(pid uint) {
+	if s.onChange != nil {
+		s.onChange(pid)
 	}
-	return nil
 }
 
 /* -------- CRUD wrappers -------- */
 
 func (s *Service) List(uid, pid uint) ([]Rule, error) {
-	if err := s.assertOwner(pid, uid); err != nil {
+	if err := s.authorize(pid, uid, org.RoleViewer); err != nil {
 		return nil, err
 	}
 	return s.repo.ListByProject(pid)
 }
 
+// Add creates a rule in in.Environment, or the default environment.
 func (s *Service) Add(uid, pid uint, in *Rule) (*Rule, error) {
-	if err := s.assertOwner(pid, uid); err != nil {
+	if err := s.authorize(pid, uid, org.RoleEditor); err != nil {
+		return nil, err
+	}
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+	if in.Environment == "" {
+		in.Environment = environment.Default
+	}
+	if err := s.checkEnv(pid, in.Environment); err != nil {
 		return nil, err
 	}
 	in.ProjectID = pid
-	return in, s.repo.Create(in)
+	if err := s.repo.Create(uid, in); err != nil {
+		return nil, err
+	}
+	s.changed(pid)
+	return in, nil
 }
 
 func (s *Service) Update(uid uint, in *Rule) error {
-	if err := s.assertOwner(in.ProjectID, uid); err != nil {
+	if err := s.authorize(in.ProjectID, uid, org.RoleEditor); err != nil {
 		return err
 	}
-	return s.repo.Update(in)
+	if err := in.Validate(); err != nil {
+		return err
+	}
+	in.Environment = "" // rules don't move between environments; promote instead
+	if err := s.repo.Update(uid, in); err != nil {
+		return err
+	}
+	s.changed(in.ProjectID)
+	return nil
 }
 
 func (s *Service) Delete(uid, pid, rid uint) error {
-	if err := s.assertOwner(pid, uid); err != nil {
+	if err := s.authorize(pid, uid, org.RoleEditor); err != nil {
 		return err
 	}
-	return s.repo.Delete(rid, pid)
+	if err := s.repo.Delete(uid, rid, pid); err != nil {
+		return err
+	}
+	s.changed(pid)
+	return nil
+}
+
+/* -------- history -------- */
+
+// Versions lists a rule's versions, newest first; they outlive the rule.
+func (s *Service) Versions(uid, pid, rid uint) ([]Version, error) {
+	if err := s.authorize(pid, uid, org.RoleViewer); err != nil {
+		return nil, err
+	}
+	vs, err := s.repo.Versions(rid, pid)
+	if err == nil && len(vs) == 0 {
+		return nil, ErrNotFound
+	}
+	return vs, err
+}
+
+// Rollback restores the rule to its state after version n, recording that
+// as a new version. A deleted rule is recreated.
+func (s *Service) Rollback(uid, pid, rid uint, n int) (*Rule, error) {
+	if err := s.authorize(pid, uid, org.RoleEditor); err != nil {
+		return nil, err
+	}
+	v, err := s.repo.Version(rid, pid, n)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoVersion
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v.Action == ActionDelete {
+		return nil, ErrDeletedVersion
+	}
+
+	var r Rule
+	if err := json.Unmarshal(v.Snapshot, &r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err // recorded before validation existed
+	}
+	if r.Environment == "" {
+		r.Environment = environment.Default // recorded before environments existed
+	}
+	if err := s.checkEnv(pid, r.Environment); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Restore(uid, &r); err != nil {
+		return nil, err
+	}
+	s.changed(pid)
+	return &r, nil
+}
+
+/* -------- declarative config -------- */
+
+// ExportConfig returns the rules of environment env as a Config, in ID
+// order.
+func (s *Service) ExportConfig(uid, pid uint, env string) (*Config, error) {
+	if err := s.authorize(pid, uid, org.RoleViewer); err != nil {
+		return nil, err
+	}
+	if err := s.checkEnv(pid, env); err != nil {
+		return nil, err
+	}
+	rules, err := s.repo.ListByProject(pid)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	cfg := &Config{Rules: []Spec{}}
+	for _, r := range rules {
+		if r.Environment == env {
+			cfg.Rules = append(cfg.Rules, specOf(r))
+		}
+	}
+	return cfg, nil
+}
+
+// ApplyConfig makes the rules of environment env match cfg and returns
+// what changed, or with dryRun what would change; a dry run changes
+// nothing, so viewers may do it.
+func (s *Service) ApplyConfig(uid, pid uint, env string, cfg *Config, dryRun bool) (*Plan, error) {
+	need := org.RoleEditor
+	if dryRun {
+		need = org.RoleViewer
+	}
+	if err := s.authorize(pid, uid, need); err != nil {
+		return nil, err
+	}
+	if err := s.checkEnv(pid, env); err != nil {
+		return nil, err
+	}
+	desired, err := cfg.rules(pid, env)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.repo.Apply(uid, pid, env, desired, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun && len(plan.Create)+len(plan.Update)+len(plan.Delete) > 0 {
+		s.changed(pid)
+	}
+	return plan, nil
+}
+
+// Promote makes environment to's rules match from's and returns what
+// changed; with dryRun it only reports the drift between them.
+func (s *Service) Promote(uid, pid uint, from, to string, dryRun bool) (*Plan, error) {
+	if from == to {
+		return nil, ErrSameEnvironment
+	}
+	cfg, err := s.ExportConfig(uid, pid, from)
+	if err != nil {
+		return nil, err
+	}
+	return s.ApplyConfig(uid, pid, to, cfg, dryRun)
 }
@@ -0,0 +1,296 @@
+package rule
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
+
+// memRepo keeps one project's rules and applies plans like the Postgres
+// repository. Versions are set by the test and not recorded.
+type memRepo struct {
+	Repository // unused methods panic
+	rules      []Rule
+	nextID     uint
+	versions   []Version
+}
+
+func (r *memRepo) Version(id, pid uint, n int) (*Version, error) {
+	for i := range r.versions {
+		if v := &r.versions[i]; v.RuleID == id && v.Version == n {
+			return v, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memRepo) Restore(uid uint, m *Rule) error {
+	for i := range r.rules {
+		if r.rules[i].ID == m.ID {
+			r.rules[i] = *m
+			return nil
+		}
+	}
+	r.rules = append(r.rules, *m)
+	return nil
+}
+
+func (r *memRepo) ListByProject(pid uint) ([]Rule, error) {
+	return append([]Rule(nil), r.rules...), nil
+}
+
+func (r *memRepo) Apply(uid, pid uint, env string, desired []Rule, dryRun bool) (*Plan, error) {
+	var existing []Rule
+	for _, x := range r.rules {
+		if x.Environment == env {
+			existing = append(existing, x)
+		}
+	}
+	plan := planConfig(existing, desired)
+	if dryRun {
+		return plan, nil
+	}
+	for _, d := range plan.Delete {
+		for i := range r.rules {
+			if r.rules[i].ID == d.ID {
+				r.rules = append(r.rules[:i], r.rules[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, u := range plan.Update {
+		for i := range r.rules {
+			if r.rules[i].ID == u.ID {
+				r.rules[i] = u.after
+			}
+		}
+	}
+	for _, c := range plan.Create {
+		r.nextID++
+		c.ID = r.nextID
+		r.rules = append(r.rules, c)
+	}
+	return plan, nil
+}
+
+const (
+	editorID uint = 1
+	viewerID uint = 2
+)
+
+// newPromoteService has prod and staging, differing in every way a plan can
+// express.
+func newPromoteService(t *testing.T) (*Service, *memRepo, *int) {
+	r := &memRepo{nextID: 100}
+	add := func(env string, specs ...Spec) {
+		rs, err := (&Config{Rules: specs}).rules(7, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, x := range rs {
+			r.nextID++
+			x.ID = r.nextID
+			r.rules = append(r.rules, x)
+		}
+	}
+	add(environment.Default, spec("/same", 10), spec("/tuned", 10), spec("/retired", 10))
+	add("staging", spec("/same", 10), spec("/tuned", 99), spec("/new", 5))
+
+	changes := 0
+	s := &Service{
+		repo: r,
+		authorize: func(pid, uid uint, need string) error {
+			if uid == editorID || need == org.RoleViewer {
+				return nil
+			}
+			return org.ErrForbidden
+		},
+		checkEnv: func(pid uint, env string) error {
+			if env != environment.Default && env != "staging" {
+				return environment.ErrNotFound
+			}
+			return nil
+		},
+	}
+	s.OnChange(func(uint) { changes++ })
+	return s, r, &changes
+}
+
+func specsIn(r *memRepo, env string) map[string]Spec {
+	out := make(map[string]Spec)
+	for _, x := range r.rules {
+		if x.Environment == env {
+			out[x.Endpoint] = specOf(x)
+		}
+	}
+	return out
+}
+
+func TestDriftReportsWithoutChanging(t *testing.T) {
+	s, r, changes := newPromoteService(t)
+	before := len(r.rules)
+
+	plan, err := s.Promote(viewerID, 7, "staging", environment.Default, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Create) != 1 || plan.Create[0].Endpoint != "/new" || plan.Create[0].Environment != environment.Default {
+		t.Errorf("create = %+v, want /new in prod", plan.Create)
+	}
+	if len(plan.Update) != 1 || plan.Update[0].Endpoint != "/tuned" {
+		t.Errorf("update = %+v, want /tuned", plan.Update)
+	}
+	if len(plan.Delete) != 1 || plan.Delete[0].Endpoint != "/retired" {
+		t.Errorf("delete = %+v, want /retired", plan.Delete)
+	}
+	if plan.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", plan.Unchanged)
+	}
+	if len(r.rules) != before || *changes != 0 {
+		t.Fatalf("drift changed the rules: %d -> %d, %d change events", before, len(r.rules), *changes)
+	}
+}
+
+func TestPromoteMakesTargetMatchSource(t *testing.T) {
+	s, r, changes := newPromoteService(t)
+
+	if _, err := s.Promote(viewerID, 7, "staging", environment.Default, false); !errors.Is(err, org.ErrForbidden) {
+		t.Fatalf("viewer promoting: %v, want ErrForbidden", err)
+	}
+	if _, err := s.Promote(editorID, 7, "staging", environment.Default, false); err != nil {
+		t.Fatal(err)
+	}
+	prod, staging := specsIn(r, environment.Default), specsIn(r, "staging")
+	if len(prod) != len(staging) {
+		t.Fatalf("prod %v, staging %v", prod, staging)
+	}
+	for ep, want := range staging {
+		if prod[ep] != want {
+			t.Errorf("prod %s = %+v, want %+v", ep, prod[ep], want)
+		}
+	}
+	if *changes != 1 {
+		t.Fatalf("%d change events, want 1", *changes)
+	}
+
+	plan, err := s.Promote(editorID, 7, "staging", environment.Default, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Create)+len(plan.Update)+len(plan.Delete) != 0 || *changes != 1 {
+		t.Fatalf("second promote = %+v with %d change events, want a no-op", plan, *changes)
+	}
+}
+
+func TestPromoteChecksEnvironments(t *testing.T) {
+	s, _, _ := newPromoteService(t)
+	if _, err := s.Promote(editorID, 7, "staging", "staging", true); !errors.Is(err, ErrSameEnvironment) {
+		t.Fatalf("same environment: %v", err)
+	}
+	if _, err := s.Promote(editorID, 7, "qa", environment.Default, true); !errors.Is(err, environment.ErrNotFound) {
+		t.Fatalf("unknown source: %v", err)
+	}
+	if _, err := s.Promote(editorID, 7, "staging", "qa", true); !errors.Is(err, environment.ErrNotFound) {
+		t.Fatalf("unknown target: %v", err)
+	}
+}
+
+func ruleByID(r *memRepo, id uint) *Rule {
+	for i := range r.rules {
+		if r.rules[i].ID == id {
+			return &r.rules[i]
+		}
+	}
+	return nil
+}
+
+func TestRollback(t *testing.T) {
+	s, r, changes := newPromoteService(t)
+	tuned := *ruleByID(r, 102) // prod /tuned, limit 10
+
+	old := tuned
+	old.LimitCount = 3
+	gone := old
+	gone.Environment = "removed"
+	legacy := old
+	legacy.Environment = "" // recorded before environments
+	broken := old
+	broken.LimitCount = 0 // recorded before validation
+	version := func(n int, action string, snap Rule) Version {
+		b, err := json.Marshal(snap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Version{RuleID: tuned.ID, ProjectID: 7, Version: n, Action: action, Snapshot: b}
+	}
+	r.versions = []Version{
+		version(1, ActionCreate, old),
+		version(2, ActionUpdate, tuned),
+		version(3, ActionUpdate, gone),
+		version(4, ActionUpdate, legacy),
+		version(5, ActionUpdate, broken),
+		version(6, ActionDelete, tuned),
+	}
+
+	fails := []struct {
+		name string
+		uid  uint
+		n    int
+		want error
+	}{
+		{"viewer", viewerID, 1, org.ErrForbidden},
+		{"no such version", editorID, 9, ErrNoVersion},
+		{"deletion", editorID, 6, ErrDeletedVersion},
+		{"environment removed since", editorID, 3, environment.ErrNotFound},
+	}
+	for _, tc := range fails {
+		if _, err := s.Rollback(tc.uid, 7, tuned.ID, tc.n); !errors.Is(err, tc.want) {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	var ve *ValidationError
+	if _, err := s.Rollback(editorID, 7, tuned.ID, 5); !errors.As(err, &ve) {
+		t.Errorf("invalid snapshot: %v, want a ValidationError", err)
+	}
+	if *changes != 0 || ruleByID(r, tuned.ID).LimitCount != 10 {
+		t.Fatal("a refused rollback changed the rule")
+	}
+
+	got, err := s.Rollback(editorID, 7, tuned.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != tuned.ID || got.LimitCount != 3 || ruleByID(r, tuned.ID).LimitCount != 3 || *changes != 1 {
+		t.Fatalf("rollback to version 1 = %+v, stored %+v, %d changes", got, ruleByID(r, tuned.ID), *changes)
+	}
+
+	got, err = s.Rollback(editorID, 7, tuned.ID, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Environment != environment.Default {
+		t.Fatalf("legacy snapshot restored into %q, want %q", got.Environment, environment.Default)
+	}
+}
+
+// Rolling back a deleted rule hands Restore its old ID.
+func TestRollbackDeleted(t *testing.T) {
+	s, r, _ := newPromoteService(t)
+	deleted := *ruleByID(r, 103) // prod /retired
+	r.rules = r.rules[:2]
+	b, _ := json.Marshal(deleted)
+	r.versions = []Version{{RuleID: deleted.ID, ProjectID: 7, Version: 1, Action: ActionCreate, Snapshot: b}}
+
+	got, err := s.Rollback(editorID, 7, deleted.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back := ruleByID(r, deleted.ID); back == nil || got.ID != deleted.ID || back.Endpoint != "/retired" {
+		t.Fatalf("restored %+v, stored %+v", got, back)
+	}
+}
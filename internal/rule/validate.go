@@ -0,0 +1,89 @@
+package rule
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/AliRizaAynaci/gorl/core"
+)
+
+// Accepted values; key_by also takes gorl's own key functions.
+var (
+	strategies   = []string{string(core.FixedWindow), string(core.SlidingWindow), string(core.TokenBucket), string(core.LeakyBucket)}
+	keyBys       = []string{string(core.KeyByAPIKey), string(core.KeyByIP), "user_id", string(core.KeyByToken), string(core.KeyByCustom)}
+	failModes    = []string{"", "open", "closed", "local"}
+	consistences = []string{"", "strict", "approximate"}
+)
+
+const (
+	maxEndpointLen = 255
+	maxWindow      = 30 * 24 * 3600 // seconds
+	minTimeoutMs   = 10             // below this no backend answers in time
+)
+
+// ValidationError maps JSON field names to what is wrong with them.
+type ValidationError struct{ Fields map[string]string }
+
+func (e *ValidationError) Error() string { return "invalid rule" }
+
+// Validate checks r and fills defaults (key_by = api_key, consistency =
+// strict). It returns a *ValidationError listing every bad field.
+func (r *Rule) Validate() error {
+	bad := make(map[string]string)
+
+	switch {
+	case r.Endpoint == "":
+		bad["endpoint"] = "is required"
+	case !strings.HasPrefix(r.Endpoint, "/"):
+		bad["endpoint"] = "must start with /"
+	case len(r.Endpoint) > maxEndpointLen:
+		bad["endpoint"] = "must be at most " + strconv.Itoa(maxEndpointLen) + " characters"
+	case strings.ContainsAny(r.Endpoint, " \t\r\n?#"):
+		bad["endpoint"] = "must be a path without whitespace, query or fragment"
+	}
+
+	if r.KeyBy == "" {
+		r.KeyBy = string(core.KeyByAPIKey)
+	}
+	if r.Consistency == "" {
+		r.Consistency = "strict"
+	}
+	oneOf(bad, "strategy", r.Strategy, strategies)
+	oneOf(bad, "key_by", r.KeyBy, keyBys)
+	oneOf(bad, "fail_mode", r.FailMode, failModes)
+	oneOf(bad, "consistency", r.Consistency, consistences)
+
+	if r.LimitCount <= 0 {
+		bad["limit_count"] = "must be positive"
+	}
+	if r.WindowSeconds <= 0 || r.WindowSeconds > maxWindow {
+		bad["window_seconds"] = "must be between 1 and " + strconv.Itoa(maxWindow)
+	}
+	if r.TimeoutMs != 0 && r.TimeoutMs < minTimeoutMs {
+		bad["timeout_ms"] = "must be 0 (project default) or at least " + strconv.Itoa(minTimeoutMs)
+	}
+
+	if len(bad) > 0 {
+		return &ValidationError{Fields: bad}
+	}
+	return nil
+}
+
+func oneOf(bad map[string]string, field, v string, allowed []string) {
+	for _, a := range allowed {
+		if v == a {
+			return
+		}
+	}
+	var names []string
+	for _, a := range allowed {
+		if a != "" {
+			names = append(names, a)
+		}
+	}
+	if v == "" {
+		bad[field] = "is required; one of " + strings.Join(names, ", ")
+		return
+	}
+	bad[field] = "must be one of " + strings.Join(names, ", ")
+}
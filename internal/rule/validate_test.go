@@ -0,0 +1,86 @@
+package rule
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func valid() Rule {
+	return Rule{Endpoint: "/pay", Strategy: "fixed_window", LimitCount: 10, WindowSeconds: 60}
+}
+
+func TestValidateFillsDefaults(t *testing.T) {
+	r := valid()
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if r.KeyBy != "api_key" || r.Consistency != "strict" {
+		t.Errorf("defaults = key_by %q, consistency %q; want api_key, strict", r.KeyBy, r.Consistency)
+	}
+}
+
+func TestValidateFieldErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		edit  func(*Rule)
+		field string // "" when the rule is valid
+	}{
+		{"missing endpoint", func(r *Rule) { r.Endpoint = "" }, "endpoint"},
+		{"relative endpoint", func(r *Rule) { r.Endpoint = "pay" }, "endpoint"},
+		{"long endpoint", func(r *Rule) { r.Endpoint = "/" + strings.Repeat("a", maxEndpointLen) }, "endpoint"},
+		{"endpoint with query", func(r *Rule) { r.Endpoint = "/pay?x=1" }, "endpoint"},
+		{"endpoint with space", func(r *Rule) { r.Endpoint = "/p ay" }, "endpoint"},
+		{"missing strategy", func(r *Rule) { r.Strategy = "" }, "strategy"},
+		{"unknown strategy", func(r *Rule) { r.Strategy = "gcra" }, "strategy"},
+		{"unknown key_by", func(r *Rule) { r.KeyBy = "cookie" }, "key_by"},
+		{"unknown fail_mode", func(r *Rule) { r.FailMode = "maybe" }, "fail_mode"},
+		{"unknown consistency", func(r *Rule) { r.Consistency = "eventual" }, "consistency"},
+		{"zero limit", func(r *Rule) { r.LimitCount = 0 }, "limit_count"},
+		{"negative limit", func(r *Rule) { r.LimitCount = -1 }, "limit_count"},
+		{"zero window", func(r *Rule) { r.WindowSeconds = 0 }, "window_seconds"},
+		{"window over 30 days", func(r *Rule) { r.WindowSeconds = maxWindow + 1 }, "window_seconds"},
+		{"negative timeout", func(r *Rule) { r.TimeoutMs = -1 }, "timeout_ms"},
+		{"timeout below minimum", func(r *Rule) { r.TimeoutMs = minTimeoutMs - 1 }, "timeout_ms"},
+		{"longest endpoint", func(r *Rule) { r.Endpoint = "/" + strings.Repeat("a", maxEndpointLen-1) }, ""},
+		{"longest window", func(r *Rule) { r.WindowSeconds = maxWindow }, ""},
+		{"project default timeout", func(r *Rule) { r.TimeoutMs = 0 }, ""},
+		{"minimum timeout", func(r *Rule) { r.TimeoutMs = minTimeoutMs }, ""},
+		{"local fail mode", func(r *Rule) { r.FailMode = "local" }, ""},
+		{"approximate", func(r *Rule) { r.Consistency = "approximate" }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.edit(&r)
+			err := r.Validate()
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("Validate() = %v, want *ValidationError", err)
+			}
+			if len(ve.Fields) != 1 || ve.Fields[tt.field] == "" {
+				t.Errorf("fields = %v, want only %s", ve.Fields, tt.field)
+			}
+		})
+	}
+}
+
+// Every bad field is reported at once, not just the first.
+func TestValidateListsEveryField(t *testing.T) {
+	r := Rule{Endpoint: "x", KeyBy: "cookie", TimeoutMs: -5}
+	var ve *ValidationError
+	if !errors.As(r.Validate(), &ve) {
+		t.Fatal("want *ValidationError")
+	}
+	for _, f := range []string{"endpoint", "strategy", "key_by", "limit_count", "window_seconds", "timeout_ms"} {
+		if ve.Fields[f] == "" {
+			t.Errorf("no error for %s in %v", f, ve.Fields)
+		}
+	}
+}
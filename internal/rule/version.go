@@ -0,0 +1,78 @@
+package rule
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Version actions.
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionRollback = "rollback"
+)
+
+// Version is an immutable record of one change to a rule. Snapshot is the
+// rule as it was after the change (for a delete: as it was just before).
+type Version struct {
+	ID        uint            `json:"id"         gorm:"primaryKey"`
+	RuleID    uint            `json:"rule_id"    gorm:"uniqueIndex:idx_rule_version"`
+	ProjectID uint            `json:"project_id" gorm:"index"`
+	Version   int             `json:"version"    gorm:"uniqueIndex:idx_rule_version"`
+	Action    string          `json:"action"`
+	UserID    uint            `json:"user_id"`
+	Snapshot  json.RawMessage `json:"snapshot"   gorm:"type:jsonb"`
+	Diff      json.RawMessage `json:"diff"       gorm:"type:jsonb"` // field -> {"from", "to"}
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (Version) TableName() string { return "rule_versions" }
+
+// change is one field's entry in Version.Diff.
+type change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// diff lists the configurable fields that differ between before and after;
+// either may be nil (create, delete).
+func diff(before, after *Rule) map[string]change {
+	b, a := fields(before), fields(after)
+	out := make(map[string]change)
+	for k, av := range a {
+		if bv, ok := b[k]; !ok || bv != av {
+			out[k] = change{From: b[k], To: av}
+		}
+	}
+	for k, bv := range b {
+		if _, ok := a[k]; !ok {
+			out[k] = change{From: bv}
+		}
+	}
+	return out
+}
+
+// fields is the user-editable part of r, keyed by JSON name.
+func fields(r *Rule) map[string]any {
+	if r == nil {
+		return nil
+	}
+	return map[string]any{
+		"endpoint":       r.Endpoint,
+		"strategy":       r.Strategy,
+		"key_by":         r.KeyBy,
+		"limit_count":    r.LimitCount,
+		"window_seconds": r.WindowSeconds,
+		"fail_open":      r.FailOpen,
+		"fail_mode":      r.FailMode,
+		"consistency":    r.Consistency,
+		"timeout_ms":     r.TimeoutMs,
+	}
+}
+
+// editable are the columns an update, rollback or apply writes.
+var editable = []string{
+	"endpoint", "strategy", "key_by", "limit_count", "window_seconds",
+	"fail_open", "fail_mode", "consistency", "timeout_ms",
+}
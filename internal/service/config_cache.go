@@ -0,0 +1,179 @@
+package service
+
+import (
+	"container/list"
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AliRizaAynaci/rlaas/internal/limiter"
+)
+
+// Cache metrics, published under "config_cache" in expvar (GET /admin/metrics).
+var (
+	cacheStats     = expvar.NewMap("config_cache")
+	cacheHits      = new(expvar.Int)
+	cacheMisses    = new(expvar.Int)
+	cacheNegHits   = new(expvar.Int) // hits on a cached "not found"
+	cacheEvictions = new(expvar.Int)
+)
+
+func init() {
+	cacheStats.Set("hits", cacheHits)
+	cacheStats.Set("misses", cacheMisses)
+	cacheStats.Set("negative_hits", cacheNegHits)
+	cacheStats.Set("evictions", cacheEvictions)
+	cacheStats.Set("hit_rate", expvar.Func(func() any {
+		h, m := cacheHits.Value(), cacheMisses.Value()
+		if h+m == 0 {
+			return 0.0
+		}
+		return float64(h) / float64(h+m)
+	}))
+}
+
+// configCache holds resolved configs per (API key, endpoint) so /check
+// doesn't query Postgres on every call. Lookups that failed with
+// ErrProjectNotFound or ErrEndpointNotOwned are cached for the shorter
+// negTTL. Found and not-found entries are kept in separate LRU lists of
+// size and negSize entries, so lookups for made-up API keys only push out
+// each other, never hot configs.
+//
+// Writes through the management API call invalidate; gen makes sure a load
+// that raced an invalidation isn't stored afterwards with stale data.
+type configCache struct {
+	ttl, negTTL   time.Duration
+	size, negSize int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *cacheEntry, in lru or neg
+	lru     *list.List               // found entries, most recently used first
+	neg     *list.List               // not-found entries, likewise
+	gen     uint64
+}
+
+type cacheEntry struct {
+	key        string
+	apiKey     string
+	keyID      uint
+	projectID  uint       // 0 for an unknown API key
+	keyExpires *time.Time // end of the key's rotation grace period
+	cfgs       []limiter.RateLimitConfig
+	err        error
+	expires    time.Time
+}
+
+// newConfigCache reads CONFIG_CACHE_TTL_MS, CONFIG_CACHE_NEGATIVE_TTL_MS,
+// CONFIG_CACHE_SIZE and CONFIG_CACHE_NEGATIVE_SIZE. A TTL of 0 disables
+// the cache.
+func newConfigCache() *configCache {
+	size := envInt("CONFIG_CACHE_SIZE", 10000)
+	return &configCache{
+		ttl:     envMs("CONFIG_CACHE_TTL_MS", 30000),
+		negTTL:  envMs("CONFIG_CACHE_NEGATIVE_TTL_MS", 5000),
+		size:    size,
+		negSize: envInt("CONFIG_CACHE_NEGATIVE_SIZE", max(size/10, 1)),
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		neg:     list.New(),
+	}
+}
+
+func cacheKey(apiKey, endpoint string) string { return apiKey + "\x00" + endpoint }
+
+// get returns the cached result for key and the generation to pass to put
+// on a miss.
+func (c *configCache) get(key string) (e *cacheEntry, gen uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gen = c.gen
+
+	if el, found := c.entries[key]; found {
+		e = el.Value.(*cacheEntry)
+		if time.Now().Before(e.expires) {
+			c.listOf(e).MoveToFront(el)
+			cacheHits.Add(1)
+			if e.err != nil {
+				cacheNegHits.Add(1)
+			}
+			return e, gen, true
+		}
+		c.removeLocked(el)
+	}
+	cacheMisses.Add(1)
+	return nil, gen, false
+}
+
+func (c *configCache) put(key string, gen uint64, e *cacheEntry) {
+	ttl, size := c.ttl, c.size
+	if e.err != nil {
+		ttl, size = c.negTTL, c.negSize
+	}
+	if c.ttl <= 0 || ttl <= 0 || size <= 0 {
+		return
+	}
+	e.key = key
+	e.expires = time.Now().Add(ttl)
+	if e.keyExpires != nil && e.keyExpires.Before(e.expires) {
+		e.expires = *e.keyExpires // stop accepting a rotated-out key on time
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return // invalidated while we were loading
+	}
+	if el, ok := c.entries[key]; ok {
+		c.removeLocked(el)
+	}
+	l := c.listOf(e)
+	c.entries[key] = l.PushFront(e)
+	for l.Len() > size {
+		c.removeLocked(l.Back())
+		cacheEvictions.Add(1)
+	}
+}
+
+func (c *configCache) listOf(e *cacheEntry) *list.List {
+	if e.err != nil {
+		return c.neg
+	}
+	return c.lru
+}
+
+func (c *configCache) removeLocked(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	c.listOf(e).Remove(el)
+	delete(c.entries, e.key)
+}
+
+// invalidateProject forgets every entry of project pid and returns the
+// counter namespaces of their limiters. Negative entries for unknown API keys are
+// kept; they expire on their own.
+func (c *configCache) invalidateProject(pid uint) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	var keys []string
+	for _, el := range c.entries {
+		if e := el.Value.(*cacheEntry); e.projectID == pid {
+			c.removeLocked(el)
+			if len(e.cfgs) > 0 {
+				keys = append(keys, e.cfgs[0].Namespace)
+			}
+		}
+	}
+	return keys
+}
+
+func envMs(key string, def int) time.Duration {
+	return time.Duration(envInt(key, def)) * time.Millisecond
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(getEnvOrDefault(key, "")); err == nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/rlaas/internal/limiter"
+)
+
+func testCache(size, negSize int) *configCache {
+	t := time.Minute
+	c := newConfigCache()
+	c.ttl, c.negTTL, c.size, c.negSize = t, t, size, negSize
+	return c
+}
+
+func found() *cacheEntry {
+	return &cacheEntry{projectID: 1, cfgs: []limiter.RateLimitConfig{{Namespace: "ns"}}}
+}
+
+func notFound() *cacheEntry { return &cacheEntry{err: ErrProjectNotFound} }
+
+func TestConfigCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := testCache(2, 2)
+	c.put("a", 0, found())
+	c.put("b", 0, found())
+	if _, _, ok := c.get("a"); !ok {
+		t.Fatal("a missing")
+	}
+	c.put("c", 0, found()) // b is now the least recently used
+
+	for k, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, _, ok := c.get(k); ok != want {
+			t.Errorf("get(%q) cached = %v, want %v", k, ok, want)
+		}
+	}
+}
+
+func TestConfigCacheCapsNegativeEntriesSeparately(t *testing.T) {
+	c := testCache(2, 2)
+	c.put("hot", 0, found())
+	for i := 0; i < 100; i++ {
+		c.put("bogus"+strconv.Itoa(i), 0, notFound())
+	}
+	if _, _, ok := c.get("hot"); !ok {
+		t.Fatal("unknown keys pushed out a found config")
+	}
+	if c.neg.Len() != 2 || len(c.entries) != 3 {
+		t.Fatalf("negative entries = %d, total = %d; want 2 and 3", c.neg.Len(), len(c.entries))
+	}
+	if _, _, ok := c.get("bogus99"); !ok {
+		t.Error("most recent unknown key not cached")
+	}
+}
+
+func TestConfigCacheInvalidate(t *testing.T) {
+	c := testCache(2, 2)
+	_, gen, _ := c.get("a")
+	c.put("b", gen, found())
+	if keys := c.invalidateProject(1); len(keys) != 1 || keys[0] != "ns" {
+		t.Fatalf("invalidateProject = %v, want [ns]", keys)
+	}
+	c.put("a", gen, found()) // loaded before the invalidation
+	if _, _, ok := c.get("a"); ok {
+		t.Error("stale load stored after invalidation")
+	}
+	if _, _, ok := c.get("b"); ok {
+		t.Error("invalidated entry still cached")
+	}
+}
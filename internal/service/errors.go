@@ -1,8 +1,14 @@
 package service
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/AliRizaAynaci/rlaas/internal/org"
+)
 
 var (
 	ErrProjectNotFound  = errors.New("project not found for given API key")
 	ErrEndpointNotOwned = errors.New("endpoint does not belong to this project")
+	ErrForbidden        = org.ErrForbidden
+	ErrKeyNotAllowed    = errors.New("API key is not allowed for this endpoint")
 )
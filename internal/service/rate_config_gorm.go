@@ -1,49 +1,237 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/AliRizaAynaci/gorl/core"
+	"github.com/AliRizaAynaci/rlaas/internal/apikey"
+	"github.com/AliRizaAynaci/rlaas/internal/environment"
 	"github.com/AliRizaAynaci/rlaas/internal/limiter"
+	"github.com/AliRizaAynaci/rlaas/internal/org"
 	"github.com/AliRizaAynaci/rlaas/internal/rule"
 	"gorm.io/gorm"
 )
 
-type RateConfigService struct{ db *gorm.DB }
+// last_used_at is written at most this often per API key.
+const touchEvery = time.Minute
 
-func NewRateConfigService(db *gorm.DB) *RateConfigService { return &RateConfigService{db} }
+type RateConfigService struct {
+	db      *gorm.DB
+	cache   *configCache
+	touched sync.Map // API key id -> time.Time of the last last_used_at write
 
-func (s *RateConfigService) Get(apiKey, endpoint string) (limiter.RateLimitConfig, error) {
-	/* 1) project id */
-	var pid uint
-	if err := s.db.Raw(`SELECT id FROM projects WHERE api_key = ?`, apiKey).
-		Scan(&pid).Error; err != nil || pid == 0 {
-		return limiter.RateLimitConfig{}, ErrProjectNotFound
+	// project id -> counter_key, remembered from lookups: a deleted
+	// project's row is gone by the time InvalidateProject hears of it
+	counterKeys sync.Map
+}
+
+func NewRateConfigService(db *gorm.DB) *RateConfigService {
+	return &RateConfigService{db: db, cache: newConfigCache()}
+}
+
+// InvalidateProject drops cached configs of a project, and the limiters
+// built from them; call it after any change to the project, its rules or
+// its keys.
+func (s *RateConfigService) InvalidateProject(projectID uint) {
+	keys := s.cache.invalidateProject(projectID)
+
+	// the project may not be cached here but still have live limiters
+	var ns string
+	s.db.Raw(`SELECT counter_key FROM projects WHERE id = ?`, projectID).Scan(&ns)
+	if ns == "" { // deleted, and its environments with it: forget them all
+		if v, ok := s.counterKeys.LoadAndDelete(projectID); ok {
+			limiter.ForgetPrefix(v.(string) + "/") // see namespace
+			keys = append(keys, v.(string))
+		}
+	} else {
+		var envs []string
+		s.db.Raw(`SELECT name FROM environments WHERE project_id = ?`, projectID).Scan(&envs)
+		for _, env := range append(envs, environment.Default) {
+			keys = append(keys, namespace(ns, env))
+		}
+	}
+	limiter.Forget(keys...)
+}
+
+// namespace keys an environment's counters: every limiter path puts its
+// counter keys under it. The default environment keeps the bare project
+// namespace, so counters from before environments carry over.
+func namespace(counterKey, env string) string {
+	if env == environment.Default {
+		return counterKey
+	}
+	return counterKey + "/" + env
+}
+
+// projectRef is the slice of a project the hot path needs.
+type projectRef struct {
+	ID               uint
+	LimiterTimeoutMs int
+	CounterKey       string
+}
+
+func (s *RateConfigService) project(ctx context.Context, id uint) (projectRef, error) {
+	var p projectRef
+	if err := s.db.WithContext(ctx).
+		Raw(`SELECT id, limiter_timeout_ms, counter_key FROM projects WHERE id = ?`, id).
+		Scan(&p).Error; err != nil {
+		return projectRef{}, err
+	}
+	if p.ID == 0 {
+		return projectRef{}, ErrProjectNotFound
+	}
+	s.counterKeys.Store(p.ID, p.CounterKey)
+	return p, nil
+}
+
+// key resolves an API key, or a rotated-out one while its grace period
+// lasts.
+func (s *RateConfigService) key(ctx context.Context, apiKey string) (*apikey.Key, error) {
+	var k apikey.Key
+	h := apikey.Hash(apiKey)
+	err := s.db.WithContext(ctx).
+		Where("hash = ? OR (previous_hash = ? AND previous_key_expires_at > NOW())", h, h).
+		First(&k).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProjectNotFound
+	}
+	return &k, err
+}
+
+// touch records a key's use, writing last_used_at at most once a minute.
+func (s *RateConfigService) touch(id uint) {
+	now := time.Now()
+	if last, ok := s.touched.Load(id); ok && now.Sub(last.(time.Time)) < touchEvery {
+		return
 	}
+	s.touched.Store(id, now)
+	go s.db.Model(&apikey.Key{}).Where("id = ?", id).Update("last_used_at", now) // best effort
+}
 
-	/* 2) rule */
-	var rl rule.Rule
-	if err := s.db.Where("project_id=? AND endpoint=?", pid, endpoint).
-		First(&rl).Error; err != nil {
-		return limiter.RateLimitConfig{}, ErrEndpointNotOwned
+// ProjectConfigs returns the rules of project pid's environment env for
+// endpoint, as /check would see them with an unrestricted key, if uid may
+// view the project.
+func (s *RateConfigService) ProjectConfigs(ctx context.Context, pid, uid uint, env, endpoint string) ([]limiter.RateLimitConfig, error) {
+	switch err := org.Authorize(s.db.WithContext(ctx), pid, uid, org.RoleViewer); {
+	case errors.Is(err, org.ErrProjectNotFound):
+		return nil, ErrProjectNotFound
+	case err != nil:
+		return nil, err
 	}
+	p, err := s.project(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	return s.rules(ctx, p, env, endpoint, func(uint) bool { return true })
+}
 
+func (s *RateConfigService) Get(ctx context.Context, apiKey, endpoint string) (limiter.RateLimitConfig, error) {
+	cfgs, err := s.GetAll(ctx, apiKey, endpoint)
+	if err != nil {
+		return limiter.RateLimitConfig{}, err
+	}
+	return cfgs[0], nil
+}
+
+// GetAll returns every rule configured for the endpoint that the key may
+// use, in ID order. Results, including not-found ones, are served from
+// the config cache; the returned slice is shared and must not be modified.
+func (s *RateConfigService) GetAll(ctx context.Context, apiKey, endpoint string) ([]limiter.RateLimitConfig, error) {
+	key := cacheKey(apiKey, endpoint)
+	e, gen, ok := s.cache.get(key)
+	if !ok {
+		e = &cacheEntry{apiKey: apiKey}
+		s.load(ctx, e, endpoint)
+		switch e.err {
+		case nil, ErrProjectNotFound, ErrEndpointNotOwned, ErrKeyNotAllowed:
+			s.cache.put(key, gen, e)
+		} // database errors are not cached
+	}
+	if e.keyID != 0 {
+		s.touch(e.keyID)
+	}
+	return e.cfgs, e.err
+}
+
+// load fills e for e.apiKey and endpoint.
+func (s *RateConfigService) load(ctx context.Context, e *cacheEntry, endpoint string) {
+	k, err := s.key(ctx, e.apiKey)
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.keyID, e.projectID = k.ID, k.ProjectID
+	if k.Hash != apikey.Hash(e.apiKey) {
+		e.keyExpires = k.PreviousKeyExpiresAt
+	}
+	if !k.AllowsEndpoint(endpoint) {
+		e.err = ErrKeyNotAllowed
+		return
+	}
+
+	p, err := s.project(ctx, k.ProjectID)
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.cfgs, e.err = s.rules(ctx, p, k.Environment, endpoint, k.AllowsRule)
+}
+
+// rules returns the configs of p's rules in env for endpoint that pass allow:
+// ErrEndpointNotOwned if there are none, ErrKeyNotAllowed if allow
+// rejected them all.
+func (s *RateConfigService) rules(ctx context.Context, p projectRef, env, endpoint string, allow func(uint) bool) ([]limiter.RateLimitConfig, error) {
+	var rules []rule.Rule
+	if err := s.db.WithContext(ctx).Where("project_id=? AND environment=? AND endpoint=?", p.ID, env, endpoint).
+		Order("id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	var out []limiter.RateLimitConfig
+	for _, rl := range rules {
+		if allow(rl.ID) {
+			out = append(out, toLimiterConfig(rl, p))
+		}
+	}
+	switch {
+	case len(rules) == 0:
+		return nil, ErrEndpointNotOwned
+	case len(out) == 0:
+		return nil, ErrKeyNotAllowed
+	}
+	return out, nil
+}
+
+func toLimiterConfig(rl rule.Rule, p projectRef) limiter.RateLimitConfig {
 	return limiter.RateLimitConfig{
-		Strategy: core.StrategyType(rl.Strategy),
-		KeyBy:    core.KeyFuncType(rl.KeyBy),
-		Limit:    rl.LimitCount,
-		Window:   time.Duration(rl.WindowSeconds) * time.Second,
-		RedisCluster: limiter.RedisClusterConfig{
-			Nodes: []string{
-				getEnvOrDefault("REDIS_NODE_1", "redis://localhost:6379/0"),
-				getEnvOrDefault("REDIS_NODE_2", "redis://localhost:6380/0"),
-				getEnvOrDefault("REDIS_NODE_3", "redis://localhost:6381/0"),
-			},
-			Strategy: getEnvOrDefault("SHARDING_STRATEGY", "hash_mod"),
-		},
-		FailOpen: rl.FailOpen,
-	}, nil
+		RuleID:      rl.ID,
+		Namespace:   namespace(p.CounterKey, rl.Environment),
+		Strategy:    core.StrategyType(rl.Strategy),
+		KeyBy:       core.KeyFuncType(rl.KeyBy),
+		Limit:       rl.LimitCount,
+		Window:      time.Duration(rl.WindowSeconds) * time.Second,
+		FailOpen:    rl.FailOpen,
+		FailMode:    rl.FailMode,
+		Consistency: rl.Consistency,
+		Timeout:     limiterTimeout(rl.TimeoutMs, p.LimiterTimeoutMs),
+	}
+}
+
+// limiterTimeout picks the rule's deadline, then the project's, then
+// LIMITER_TIMEOUT_MS.
+func limiterTimeout(ruleMs, projectMs int) time.Duration {
+	ms := ruleMs
+	if ms <= 0 {
+		ms = projectMs
+	}
+	if ms <= 0 {
+		ms, _ = strconv.Atoi(getEnvOrDefault("LIMITER_TIMEOUT_MS", "500"))
+	}
+	return time.Duration(ms) * time.Millisecond
 }
 
 func getEnvOrDefault(key, def string) string {
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AliRizaAynaci/rlaas/internal/limiter"
+	"github.com/AliRizaAynaci/rlaas/internal/rule"
+)
+
+func TestNamespace(t *testing.T) {
+	if got := namespace("abc", "prod"); got != "abc" {
+		t.Errorf("prod namespace = %q, want the bare counter key", got)
+	}
+	if got := namespace("abc", "staging"); got != "abc/staging" {
+		t.Errorf("staging namespace = %q, want abc/staging", got)
+	}
+}
+
+// The same rule in two environments must not share counters for a client
+// key, whether it is an endpoint's only rule or one of several.
+func TestEnvironmentsCountIndependently(t *testing.T) {
+	t.Setenv("LIMITER_BACKEND", limiter.BackendMemory)
+	if err := limiter.InitSharding(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// a fresh namespace: the memory backend outlives a run with -count
+	p := projectRef{ID: 1, CounterKey: "envtest-" + strconv.FormatInt(time.Now().UnixNano(), 36)}
+
+	cfg := func(env string, id uint) limiter.RateLimitConfig {
+		return toLimiterConfig(rule.Rule{
+			ID: id, Environment: env, Endpoint: "/pay",
+			Strategy: "fixed_window", KeyBy: "ip", LimitCount: 2, WindowSeconds: 60,
+		}, p)
+	}
+
+	tests := []struct {
+		name  string
+		allow func(env string) bool
+	}{
+		{"single rule", func(env string) bool {
+			c := cfg(env, 1)
+			return limiter.AllowAll(ctx, c.Namespace, "/pay", "10.0.0.1", []limiter.RateLimitConfig{c}).Allowed
+		}},
+		{"several rules", func(env string) bool {
+			cs := []limiter.RateLimitConfig{cfg(env, 2), cfg(env, 3)}
+			return limiter.AllowAll(ctx, cs[0].Namespace, "/pay", "10.0.0.2", cs).Allowed
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				if !tt.allow("prod") {
+					t.Fatalf("prod request %d denied", i+1)
+				}
+			}
+			if tt.allow("prod") {
+				t.Fatal("prod allowed past its limit")
+			}
+			for i := 0; i < 2; i++ {
+				if !tt.allow("staging") {
+					t.Fatalf("staging request %d denied after prod was used up", i+1)
+				}
+			}
+			if tt.allow("staging") {
+				t.Fatal("staging allowed past its limit")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/AliRizaAynaci/rlaas/internal/httperr"
+)
+
+type Handler struct{ svc *Service }
+
+func NewHandler(s *Service) *Handler { return &Handler{s} }
+
+// GET /tokens
+func (h *Handler) List(c *fiber.Ctx) error {
+	uid := c.Locals("user_id").(uint)
+	ts, err := h.svc.List(uid)
+	if err != nil {
+		return httperr.Internal(err)
+	}
+	return c.JSON(ts)
+}
+
+// POST /tokens  { "name": "ci", "scopes": ["rules:write"], "expires_in_days": 90 }
+// The secret is in the response only.
+func (h *Handler) Create(c *fiber.Ctx) error {
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"` // 0 = never
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return httperr.BadRequest("body is not valid JSON")
+	}
+
+	bad := make(map[string]string)
+	if req.Name == "" || len(req.Name) > 100 {
+		bad["name"] = "must be 1-100 characters"
+	}
+	if len(req.Scopes) == 0 {
+		bad["scopes"] = "at least one of read, rules:write, projects:admin"
+	}
+	for _, s := range req.Scopes {
+		if scopeRank[s] == 0 {
+			bad["scopes"] = "unknown scope " + s + "; use read, rules:write or projects:admin"
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		bad["expires_in_days"] = "must not be negative"
+	}
+	if len(bad) > 0 {
+		return httperr.Invalid(bad)
+	}
+
+	var exp *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		exp = &t
+	}
+	t, secret, err := h.svc.Create(c.Locals("user_id").(uint), req.Name, req.Scopes, exp)
+	if err != nil {
+		return httperr.Internal(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"token": secret, "details": t})
+}
+
+// DELETE /tokens/:id
+func (h *Handler) Revoke(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+	err := h.svc.Revoke(c.Locals("user_id").(uint), uint(id))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return httperr.NotFound(err.Error())
+	case err != nil:
+		return httperr.Internal(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,44 @@
+package token
+
+import (
+	"strings"
+	"time"
+)
+
+// Scopes, from narrowest to widest; each includes the ones before it.
+const (
+	ScopeRead          = "read"
+	ScopeRulesWrite    = "rules:write"
+	ScopeProjectsAdmin = "projects:admin"
+)
+
+var scopeRank = map[string]int{ScopeRead: 1, ScopeRulesWrite: 2, ScopeProjectsAdmin: 3}
+
+// Token is a personal access token. Only a SHA-256 of the secret is
+// stored; Prefix is kept so users can tell tokens apart.
+type Token struct {
+	ID         uint       `json:"id"           gorm:"primaryKey"`
+	UserID     uint       `json:"user_id"      gorm:"index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"            gorm:"uniqueIndex"`
+	Scopes     string     `json:"scopes"` // comma separated
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (Token) TableName() string { return "personal_access_tokens" }
+
+// ScopeList returns the token's scopes.
+func (t *Token) ScopeList() []string { return strings.Split(t.Scopes, ",") }
+
+// Allows reports whether any of granted includes need.
+func Allows(granted []string, need string) bool {
+	for _, g := range granted {
+		if scopeRank[g] >= scopeRank[need] {
+			return true
+		}
+	}
+	return false
+}
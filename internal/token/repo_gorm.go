@@ -0,0 +1,33 @@
+package token
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type gormRepo struct{ db *gorm.DB }
+
+func NewGormRepo(db *gorm.DB) Repository { return &gormRepo{db} }
+
+func (r *gormRepo) Create(t *Token) error { return r.db.Create(t).Error }
+
+func (r *gormRepo) ListByUser(uid uint) ([]Token, error) {
+	var ts []Token
+	return ts, r.db.Where("user_id = ?", uid).Order("created_at DESC").Find(&ts).Error
+}
+
+func (r *gormRepo) FindByHash(hash string) (*Token, error) {
+	var t Token
+	return &t, r.db.Where("hash = ?", hash).First(&t).Error
+}
+
+// Delete reports false when no token matched (id, uid).
+func (r *gormRepo) Delete(id, uid uint) (bool, error) {
+	res := r.db.Where("id = ? AND user_id = ?", id, uid).Delete(&Token{})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *gormRepo) Touch(id uint, at time.Time) error {
+	return r.db.Model(&Token{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
@@ -0,0 +1,11 @@
+package token
+
+import "time"
+
+type Repository interface {
+	Create(*Token) error
+	ListByUser(uid uint) ([]Token, error)
+	FindByHash(hash string) (*Token, error)
+	Delete(id, uid uint) (bool, error)
+	Touch(id uint, at time.Time) error
+}